@@ -21,7 +21,7 @@ var httpClient = &http.Client{
 		httplog.SkipReqBody(http.MethodPost, "/skipped-path1", "/skipped-path2"),
 		httplog.SkipRequest(http.MethodPost, "/totaly-skipped-post-request"),
 		httplog.SkipResponse(http.MethodGet, "/do-not-log-response-at-all"),
-		httplog.SkipReqBody(http.MethodPost, "/do-not-log-response-body"),
+		httplog.SkipRespBody(http.MethodPost, "/do-not-log-response-body"),
 	),
 	Timeout: 20 * time.Second,
 }
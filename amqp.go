@@ -2,6 +2,8 @@ package logger
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/streadway/amqp"
@@ -10,6 +12,24 @@ import (
 
 const amqpDeliveryName = "x-log-process-id"
 
+// amqpDebug holds secret and max lifetime of debug tokens accepted by FromAMQP
+var amqpDebug atomic.Value
+
+type debugVerifier struct {
+	secret      []byte
+	maxLifetime time.Duration
+}
+
+// SetAMQPDebugSecret makes FromAMQP turn debug logging on for deliveries carrying a token made by NewDebugToken
+// with secret and expiring within maxLifetime, see VerifyDebugToken. Until it is called debug tokens of deliveries
+// are ignored, nil secret turns them off again
+func SetAMQPDebugSecret(secret []byte, maxLifetime time.Duration) {
+	amqpDebug.Store(debugVerifier{secret: secret, maxLifetime: maxLifetime})
+}
+
+// FromAMQP binds process_id of delivery to ctx and returns logger of it.
+// Returned context collects fields of a canonical log line, see FlushCanonical,
+// and is logged at debug level if the publisher had debug logging on with a valid token, see SetAMQPDebugSecret
 func FromAMQP(ctx context.Context, e amqp.Delivery, namespace string) (context.Context, *zap.Logger) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -23,6 +43,12 @@ func FromAMQP(ctx context.Context, e amqp.Delivery, namespace string) (context.C
 	BindFields(ctx, MQMessageID(e.MessageId))
 
 	ctx = BindProcessID(ctx, processID)
+	if token, ok := e.Headers[HTTPHeaderDebug].(string); ok {
+		if v, _ := amqpDebug.Load().(debugVerifier); VerifyDebugToken(v.secret, token, time.Now(), v.maxLifetime) {
+			ctx = BindDebug(ctx, token)
+		}
+	}
+	ctx = WithCanonical(ctx)
 
 	return ctx, FromCtx(ctx, namespace)
 }
@@ -32,6 +58,9 @@ func ToAMQPHeader(ctx context.Context, table amqp.Table) amqp.Table {
 		table = make(map[string]interface{})
 	}
 	table[amqpDeliveryName] = GetProcessID(ctx)
+	if token, ok := GetDebug(ctx); ok {
+		table[HTTPHeaderDebug] = token
+	}
 
 	return table
 }
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// canonical accumulates fields of one unit of work, shared by every context derived from the one it was bound to
+type canonical struct {
+	mu     sync.Mutex
+	fields []zap.Field
+}
+
+// WithCanonical returns a context collecting fields for a canonical log line: one entry written at the end of
+// a unit of work (http request, AMQP message, job run) carrying every fact added with AddToCanonical.
+// Context which already collects fields is returned as is
+func WithCanonical(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(CanonicalKey).(*canonical); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, CanonicalKey, &canonical{})
+}
+
+// AddToCanonical adds fields to the canonical log line of ctx, a field replaces earlier one with the same key.
+// Fields are dropped if ctx doesn't collect them, see WithCanonical. Safe for concurrent use
+func AddToCanonical(ctx context.Context, fields ...zap.Field) {
+	c, ok := ctx.Value(CanonicalKey).(*canonical)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range fields {
+		replaced := false
+		for i := range c.fields {
+			if c.fields[i].Key == f.Key {
+				c.fields[i], replaced = f, true
+				break
+			}
+		}
+		if !replaced {
+			c.fields = append(c.fields, f)
+		}
+	}
+}
+
+// CanonicalFields returns fields added to ctx so far, for instrumentation writing the line itself
+func CanonicalFields(ctx context.Context) []zap.Field {
+	c, ok := ctx.Value(CanonicalKey).(*canonical)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]zap.Field(nil), c.fields...)
+}
+
+// FlushCanonical writes the canonical log line of ctx at info level with fields added so far and extra ones,
+// then starts collecting anew, so a worker may reuse ctx for the next unit of work:
+//
+//	ctx, l := logger.FromAMQP(ctx, delivery, "consumer")
+//	defer logger.FlushCanonical(ctx, l, "message handled")
+//	logger.AddToCanonical(ctx, logger.ProspectID(id), zap.String("decision", decision))
+func FlushCanonical(ctx context.Context, l *zap.Logger, msg string, fields ...zap.Field) {
+	var all []zap.Field
+	if c, ok := ctx.Value(CanonicalKey).(*canonical); ok {
+		c.mu.Lock()
+		all, c.fields = c.fields, nil
+		c.mu.Unlock()
+	}
+
+	l.Info(msg, append(all, fields...)...)
+}
@@ -0,0 +1,47 @@
+package logger_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"libs/logger"
+)
+
+func TestCanonical(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(core)
+
+	logger.AddToCanonical(context.Background(), logger.ProspectID("dropped"))
+
+	ctx := logger.WithCanonical(context.Background())
+	assert.Equal(t, ctx, logger.WithCanonical(ctx), "existing line is reused")
+
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.AddToCanonical(child, zap.String("decision", "approve"))
+		}()
+	}
+	wg.Wait()
+	logger.AddToCanonical(ctx, logger.ProspectID("p-1"), zap.String("decision", "reject"))
+	assert.Len(t, logger.CanonicalFields(ctx), 2, "fields with the same key are replaced")
+
+	logger.FlushCanonical(ctx, l, "message handled", zap.Int("retries", 1))
+	logger.FlushCanonical(ctx, l, "next message")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, map[string]interface{}{"prospect_id": "p-1", "decision": "reject", "retries": int64(1)}, entries[0].ContextMap())
+	assert.Empty(t, entries[1].ContextMap(), "flush starts a new line")
+}
@@ -6,6 +6,8 @@ const (
 	RequestIDKey  correlationIDCtxKey = "loggerRequestIDKey"
 	BindFieldsKey correlationIDCtxKey = "loggerBindFields"
 	ProcessIDKey  correlationIDCtxKey = "loggerProcessIDKey"
+	CanonicalKey  correlationIDCtxKey = "loggerCanonical"
+	DebugKey      correlationIDCtxKey = "loggerDebug"
 )
 
 const (
@@ -18,4 +20,5 @@ const (
 const (
 	HTTPHeaderRequestID = "x-log-request-id"
 	HTTPHeaderProcessID = "x-log-process-id"
+	HTTPHeaderDebug     = "x-log-debug"
 )
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BindDebug returns a context logged at debug level whatever the global level is: loggers returned by
+// FromCtx and WithContext for it write debug entries. token is passed further by instrumentation
+// (ToAMQPHeader, httplog.PassDebug), so downstream services can verify it and debug the same flow
+func BindDebug(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, DebugKey, token)
+}
+
+// GetDebug returns token bound by BindDebug and whether debug logging is on for ctx
+func GetDebug(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(DebugKey).(string)
+	return token, ok
+}
+
+// NewDebugToken returns token enabling debug logging until expiry for services sharing secret:
+// "<unix expiry>.<hex HMAC-SHA256 of expiry>"
+func NewDebugToken(secret []byte, expiry time.Time) string {
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	return exp + "." + debugSignature(secret, exp)
+}
+
+// DefaultDebugTokenLifetime is the longest time debug tokens are accepted for by instrumentation unless configured otherwise
+const DefaultDebugTokenLifetime = time.Hour
+
+// VerifyDebugToken reports whether token was made by NewDebugToken with secret and hasn't expired.
+// Tokens expiring more than maxLifetime after now are rejected, so a leaked token can't be used for long
+// and tokens can't be made everlasting
+func VerifyDebugToken(secret []byte, token string, now time.Time, maxLifetime time.Duration) bool {
+	i := strings.IndexByte(token, '.')
+	if i < 0 || len(secret) == 0 {
+		return false
+	}
+	exp, sig := token[:i], token[i+1:]
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > unix || unix > now.Add(maxLifetime).Unix() {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(debugSignature(secret, exp)))
+}
+
+func debugSignature(secret []byte, exp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(exp))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// levelCore applies the global level of New on top of its cores, so it can be lowered for one logger
+type levelCore struct {
+	zapcore.Core
+	min zapcore.Level
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.min && c.Core.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), min: c.min}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.min {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
+// withDebug lowers level of l to debug. Loggers not created by New are returned as is
+func withDebug(l *zap.Logger) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if lc, ok := core.(*levelCore); ok && lc.min > zapcore.DebugLevel {
+			return &levelCore{Core: lc.Core, min: zapcore.DebugLevel}
+		}
+		return core
+	}))
+}
@@ -0,0 +1,98 @@
+package logger_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+func TestDebugToken(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now()
+	token := logger.NewDebugToken(secret, now.Add(time.Hour))
+
+	const lifetime = logger.DefaultDebugTokenLifetime
+	assert.True(t, logger.VerifyDebugToken(secret, token, now, lifetime))
+	assert.False(t, logger.VerifyDebugToken(secret, token, now.Add(2*time.Hour), lifetime), "expired")
+	assert.False(t, logger.VerifyDebugToken([]byte("other"), token, now, lifetime), "other secret")
+	assert.False(t, logger.VerifyDebugToken(nil, token, now, lifetime), "no secret")
+	assert.False(t, logger.VerifyDebugToken(secret, strings.Replace(token, ".", "0.", 1), now, lifetime), "expiry changed")
+	for _, broken := range []string{"", "1", "x.y", token[:len(token)-1]} {
+		assert.False(t, logger.VerifyDebugToken(secret, broken, now, lifetime), broken)
+	}
+
+	longLived := logger.NewDebugToken(secret, time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(t, logger.VerifyDebugToken(secret, longLived, now, lifetime), "expiry beyond max lifetime")
+	assert.False(t, logger.VerifyDebugToken(secret, token, now, 30*time.Minute), "shorter max lifetime")
+}
+
+func TestBindDebug(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	logger.New("info", "test")
+	os.Stdout = stdout
+	defer logger.New("info", "test", zap.WrapCore(func(c zapcore.Core) zapcore.Core { return zapcore.NewNopCore() }))
+
+	ctx := logger.BindProcessID(context.Background(), "p-1")
+	debugCtx := logger.BindDebug(ctx, "token")
+
+	logger.FromCtx(ctx, "").Debug("hidden")
+	logger.FromCtx(debugCtx, "").Debug("from ctx")
+	logger.WithContext(logger.FromCtx(context.Background(), "dep").With(zap.String("dep", "x")), debugCtx).Debug("with context")
+	logger.FromCtx(debugCtx, "").Info("info once")
+	logger.FromCtx(debugCtx, "").Error("error goes to stderr")
+	require.NoError(t, w.Close())
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"msg":"from ctx"`)
+	assert.Contains(t, lines[0], `"process_id":"p-1"`)
+	assert.Contains(t, lines[1], `"msg":"with context"`)
+	assert.Contains(t, lines[1], `"dep":"x"`, "fields of the logger are kept")
+	assert.Contains(t, lines[2], `"msg":"info once"`)
+}
+
+func TestDebugOverAMQP(t *testing.T) {
+	secret := []byte("s3cret")
+	token := logger.NewDebugToken(secret, time.Now().Add(time.Minute))
+	ctx := logger.BindDebug(context.Background(), token)
+	headers := logger.ToAMQPHeader(ctx, nil)
+	assert.Equal(t, token, headers[logger.HTTPHeaderDebug])
+
+	debugOf := func(headers amqp.Table) (string, bool) {
+		got, _ := logger.FromAMQP(context.Background(), amqp.Delivery{Headers: headers}, "consumer")
+		return logger.GetDebug(got)
+	}
+
+	_, ok := debugOf(headers)
+	assert.False(t, ok, "ignored until a secret is set")
+
+	logger.SetAMQPDebugSecret(secret, logger.DefaultDebugTokenLifetime)
+	defer logger.SetAMQPDebugSecret(nil, 0)
+
+	got, ok := debugOf(headers)
+	assert.True(t, ok)
+	assert.Equal(t, token, got)
+
+	_, ok = debugOf(amqp.Table{logger.HTTPHeaderDebug: "9999999999.forged"})
+	assert.False(t, ok, "forged token")
+	_, ok = debugOf(logger.ToAMQPHeader(context.Background(), nil))
+	assert.False(t, ok, "no token")
+}
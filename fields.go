@@ -22,9 +22,12 @@ var (
 
 const (
 	ApplicationIDKey = "application_id"
+	AttemptKey       = "attempt"
 	ContactIDKey     = "contact_id"
 	IABSClientIDKey  = "iabs_client_id"
 	MQMessageIDKey   = "mq_message_id"
+	OutgoingReqIDKey = "outgoing_request_id"
+	ParentReqIDKey   = "parent_request_id"
 	RequestDumpKey   = "request_dump"
 	ResponseDumpKey  = "response_dump"
 	ProductIDKey     = "product_id"
@@ -77,3 +80,20 @@ func ResponseDump(body []byte) zap.Field {
 func MQMessageID(id string) zap.Field {
 	return zap.String(MQMessageIDKey, id)
 }
+
+// OutgoingRequestID generates zap.Field with outgoing_request_id
+// request_id sent to another service in x-log-request-id header
+func OutgoingRequestID(id string) zap.Field {
+	return zap.String(OutgoingReqIDKey, id)
+}
+
+// ParentRequestID generates zap.Field with parent_request_id
+// outgoing_request_id of the previous hop (retry or redirect) of the same call
+func ParentRequestID(id string) zap.Field {
+	return zap.String(ParentReqIDKey, id)
+}
+
+// Attempt generates zap.Field with attempt number, starting from 1
+func Attempt(n int) zap.Field {
+	return zap.Int(AttemptKey, n)
+}
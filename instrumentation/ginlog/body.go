@@ -0,0 +1,174 @@
+package ginlog
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"libs/logger"
+)
+
+// DefaultBodyContentTypes are captured by CaptureBodies unless changed by CaptureContentTypes
+var DefaultBodyContentTypes = []string{
+	"application/json",
+	"*+json",
+	"application/xml",
+	"text/xml",
+	"*+xml",
+	"application/x-www-form-urlencoded",
+	"text/plain",
+}
+
+// CaptureBodies logs request and response bodies of routes as request_dump/response_dump, every route if none given.
+// Routes are patterns as in Skip. Up to limit bytes of each body are logged, the handler still reads
+// the whole request body. Only bodies of CaptureContentTypes are logged, values are masked by redaction rules
+func CaptureBodies(limit int, routes ...string) Option {
+	return optionFunc(func(c *config) {
+		c.bodyLimit = limit
+		c.bodyRoutes = routes
+	})
+}
+
+// CaptureContentTypes replaces content types of bodies logged by CaptureBodies:
+// exact media type, "text/*" for a whole type or "*+json" for a suffix
+func CaptureContentTypes(types ...string) Option {
+	return optionFunc(func(c *config) {
+		c.bodyTypes = types
+	})
+}
+
+// RedactFields masks values of JSON and urlencoded form fields in captured bodies,
+// names are case-insensitive
+func RedactFields(names ...string) Option {
+	return optionFunc(func(c *config) {
+		c.redact = c.redact.WithFields(names...)
+	})
+}
+
+// captures reports whether bodies of request routed to route by urlPath are logged
+func (cfg *config) captures(route, urlPath string) bool {
+	if cfg.bodyLimit <= 0 {
+		return false
+	}
+
+	return len(cfg.bodyRoutes) == 0 || matchAny(cfg.bodyRoutes, route, urlPath)
+}
+
+func (cfg *config) capturedType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range cfg.bodyTypes {
+		switch {
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		case strings.HasPrefix(t, "*"):
+			if strings.HasSuffix(mediaType, strings.TrimPrefix(t, "*")) {
+				return true
+			}
+		case t == mediaType:
+			return true
+		}
+	}
+
+	return false
+}
+
+// bodyFields returns dump fields of captured bodies
+func (cfg *config) bodyFields(c *gin.Context, req *capture, resp *bodyWriter) []zap.Field {
+	var fields []zap.Field
+	if contentType := c.Request.Header.Get("Content-Type"); cfg.capturedType(contentType) {
+		fields = append(fields, logger.RequestDump(cfg.redact.Body(contentType, req.body())))
+		if req.truncated() {
+			fields = append(fields, zap.Bool("request_dump_truncated", true))
+		}
+	}
+	if contentType := c.Writer.Header().Get("Content-Type"); cfg.capturedType(contentType) {
+		fields = append(fields, logger.ResponseDump(cfg.redact.Body(contentType, resp.body())))
+		if resp.truncated() {
+			fields = append(fields, zap.Bool("response_dump_truncated", true))
+		}
+	}
+
+	return fields
+}
+
+// capture keeps up to limit bytes, one more byte is kept to detect truncation
+type capture struct {
+	limit int
+	buf   bytes.Buffer
+}
+
+func (cp *capture) write(b []byte) {
+	if rest := cp.limit + 1 - cp.buf.Len(); rest > 0 {
+		if len(b) > rest {
+			b = b[:rest]
+		}
+		cp.buf.Write(b)
+	}
+}
+
+func (cp *capture) body() []byte {
+	if cp.truncated() {
+		return cp.buf.Bytes()[:cp.limit]
+	}
+
+	return cp.buf.Bytes()
+}
+
+func (cp *capture) truncated() bool {
+	return cp.buf.Len() > cp.limit
+}
+
+// captureRequest reads up to limit+1 bytes of request body and puts them back in front of the rest,
+// so handlers read the body as it was sent
+func captureRequest(c *gin.Context, limit int) *capture {
+	cp := &capture{limit: limit}
+	body := c.Request.Body
+	if body == nil {
+		return cp
+	}
+
+	head, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	cp.write(head)
+	rest := io.Reader(body)
+	if err != nil {
+		rest = errReader{err}
+	}
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), rest), body}
+
+	return cp
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// bodyWriter passes response to the client capturing its first bytes
+type bodyWriter struct {
+	gin.ResponseWriter
+	capture
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,59 @@
+package ginlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(CaptureBodies(32, "/payments/*rest", "/echo"), RedactFields("pin")))
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		c.Data(http.StatusOK, c.ContentType(), body)
+	}
+	r.POST("/payments/*rest", echo)
+	r.POST("/echo", echo)
+	r.POST("/other", echo)
+
+	send := func(path, contentType, body string) (string, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := serve(r, req)
+		entries := logs.TakeAll()
+		require.Len(t, entries, 1)
+		return w.Body.String(), entries[0].ContextMap()
+	}
+
+	body, fields := send("/payments/1", "application/json", `{"card":"4000","pin":"1234"}`)
+	assert.Equal(t, `{"card":"4000","pin":"1234"}`, body, "handler and client see original bodies")
+	assert.Equal(t, `{"card":"4000","pin":"[REDACTED]"}`, fields["request_dump"])
+	assert.Equal(t, `{"card":"4000","pin":"[REDACTED]"}`, fields["response_dump"])
+	assert.NotContains(t, fields, "request_dump_truncated")
+
+	long := `{"items":"` + strings.Repeat("x", 100) + `"}`
+	body, fields = send("/echo", "application/json; charset=utf-8", long)
+	assert.Equal(t, long, body, "handler reads past the limit")
+	assert.Equal(t, long[:32], fields["request_dump"])
+	assert.Equal(t, long[:32], fields["response_dump"])
+	assert.Equal(t, true, fields["request_dump_truncated"])
+	assert.Equal(t, true, fields["response_dump_truncated"])
+
+	_, fields = send("/echo", "application/octet-stream", "binary")
+	assert.NotContains(t, fields, "request_dump", "content type is not captured")
+	assert.NotContains(t, fields, "response_dump")
+
+	_, fields = send("/other", "application/json", `{}`)
+	assert.NotContains(t, fields, "request_dump", "route is not captured")
+}
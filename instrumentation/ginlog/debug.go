@@ -0,0 +1,71 @@
+package ginlog
+
+import (
+	"net"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"libs/logger"
+)
+
+// WithDebugHeader logs requests carrying logger.HTTPHeaderDebug header at debug level whatever the global level is.
+// The header must hold a token made by logger.NewDebugToken with secret, or come from a peer in one of
+// trusted networks (CIDR, e.g. "10.0.0.0/8"). The peer is the address of the connection, forwarding headers
+// are not taken into account. Debug logging is bound to the request context with logger.BindDebug
+// and passed on by logger.ToAMQPHeader and httplog.PassDebug: a verified token as it came, for a trusted peer
+// a fresh token signed with secret, or TrustedDebugToken without secret.
+// Tokens expiring later than WithDebugTokenLifetime are rejected. It panics if a network is invalid
+func WithDebugHeader(secret []byte, trustedNetworks ...string) Option {
+	nets := make([]*net.IPNet, len(trustedNetworks))
+	for i, cidr := range trustedNetworks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+
+	return optionFunc(func(c *config) {
+		c.debugSecret = secret
+		c.debugNets = nets
+	})
+}
+
+// WithDebugTokenLifetime sets the longest time before expiry of tokens accepted by WithDebugHeader,
+// logger.DefaultDebugTokenLifetime by default
+func WithDebugTokenLifetime(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.debugLifetime = d
+	})
+}
+
+// TrustedDebugToken is bound to requests of trusted networks when WithDebugHeader has no secret to sign a token.
+// It never passes verification, so downstream services don't trust it
+const TrustedDebugToken = "trusted-network"
+
+// debugToken returns debug token of the request if its debug header may enable debug logging
+func (cfg *config) debugToken(c *gin.Context) (string, bool) {
+	token := c.GetHeader(logger.HTTPHeaderDebug)
+	if token == "" || (len(cfg.debugSecret) == 0 && len(cfg.debugNets) == 0) {
+		return "", false
+	}
+	now := time.Now()
+	if logger.VerifyDebugToken(cfg.debugSecret, token, now, cfg.debugLifetime) {
+		return token, true
+	}
+	if ip := net.ParseIP(c.RemoteIP()); ip != nil {
+		for _, n := range cfg.debugNets {
+			if !n.Contains(ip) {
+				continue
+			}
+			// the header value is not verified, it is never passed on
+			if len(cfg.debugSecret) == 0 {
+				return TrustedDebugToken, true
+			}
+			return logger.NewDebugToken(cfg.debugSecret, now.Add(cfg.debugLifetime)), true
+		}
+	}
+
+	return "", false
+}
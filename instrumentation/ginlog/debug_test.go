@@ -0,0 +1,102 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"libs/logger"
+)
+
+func TestWithDebugHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+	secret := []byte("s3cret")
+
+	// gin trusts forwarding headers of every peer by default
+	r := gin.New()
+	r.Use(New(WithDebugHeader(secret, "10.1.0.0/16")))
+	var debugToken string
+	var debugOn bool
+	r.GET("/", func(c *gin.Context) { debugToken, debugOn = logger.GetDebug(c.Request.Context()) })
+
+	valid := logger.NewDebugToken(secret, time.Now().Add(time.Minute))
+	tests := []struct {
+		name   string
+		token  string
+		remote string
+		want   bool
+	}{
+		{"signed token", valid, "192.0.2.1:1000", true},
+		{"expired token", logger.NewDebugToken(secret, time.Now().Add(-time.Minute)), "192.0.2.1:1000", false},
+		{"forged token", "9999999999.abc", "192.0.2.1:1000", false},
+		{"long-lived token", logger.NewDebugToken(secret, time.Now().Add(2*logger.DefaultDebugTokenLifetime)), "192.0.2.1:1000", false},
+		{"trusted network", "1", "10.1.2.3:1000", true},
+		{"spoofed forwarded for", "1", "192.0.2.1:1000", false},
+		{"no header", "", "10.1.2.3:1000", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debugToken, debugOn = "", false
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.token != "" {
+				req.Header.Set(logger.HTTPHeaderDebug, tt.token)
+			}
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			serve(r, req)
+
+			assert.Equal(t, tt.want, debugOn)
+			entries := logs.TakeAll()
+			require.Len(t, entries, 1)
+			if tt.want {
+				if tt.remote == "192.0.2.1:1000" {
+					assert.Equal(t, tt.token, debugToken, "verified token is passed on")
+				} else {
+					assert.NotEqual(t, tt.token, debugToken, "unverified header is not passed on")
+					assert.True(t, logger.VerifyDebugToken(secret, debugToken, time.Now(), logger.DefaultDebugTokenLifetime), "fresh token")
+				}
+				assert.Equal(t, true, entries[0].ContextMap()["debug"])
+			} else {
+				assert.NotContains(t, entries[0].ContextMap(), "debug")
+			}
+		})
+	}
+}
+
+func TestDebugHeaderIsOptIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe(t)
+
+	r := gin.New()
+	r.Use(New())
+	var debugOn bool
+	r.GET("/", func(c *gin.Context) { _, debugOn = logger.GetDebug(c.Request.Context()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(logger.HTTPHeaderDebug, "1")
+	serve(r, req)
+	assert.False(t, debugOn)
+}
+
+func TestTrustedNetworkWithoutSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe(t)
+
+	r := gin.New()
+	r.Use(New(WithDebugHeader(nil, "10.1.0.0/16")))
+	var debugToken string
+	r.GET("/", func(c *gin.Context) { debugToken, _ = logger.GetDebug(c.Request.Context()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:1000"
+	req.Header.Set(logger.HTTPHeaderDebug, "anything")
+	serve(r, req)
+	assert.Equal(t, TrustedDebugToken, debugToken)
+}
@@ -0,0 +1,52 @@
+package ginlog
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// ginErrors logs errors added with c.Error with their type and meta
+type ginErrors []*gin.Error
+
+func (errs ginErrors) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range errs {
+		if err := enc.AppendObject(ginError{e}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type ginError struct {
+	*gin.Error
+}
+
+func (e ginError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("error", e.Error.Error())
+	enc.AddString("type", errorTypeName(e.Type))
+	if e.Meta != nil {
+		return enc.AddReflected("meta", e.Meta)
+	}
+
+	return nil
+}
+
+func errorTypeName(t gin.ErrorType) string {
+	switch t {
+	case gin.ErrorTypeBind:
+		return "bind"
+	case gin.ErrorTypeRender:
+		return "render"
+	case gin.ErrorTypePrivate:
+		return "private"
+	case gin.ErrorTypePublic:
+		return "public"
+	case gin.ErrorTypeAny:
+		return "any"
+	default:
+		return strconv.FormatUint(uint64(t), 10)
+	}
+}
@@ -0,0 +1,95 @@
+package ginlog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestErrorsEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New())
+	r.POST("/bind", func(c *gin.Context) {
+		_ = c.Error(errors.New("name is required")).SetType(gin.ErrorTypeBind).SetMeta(map[string]string{"field": "name"})
+		c.Status(http.StatusBadRequest)
+	})
+	r.POST("/private", func(c *gin.Context) {
+		_ = c.Error(errors.New("db is down"))
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		path   string
+		level  zapcore.Level
+		status int64
+		errors []interface{}
+	}{
+		{
+			"/bind", zapcore.WarnLevel, http.StatusBadRequest,
+			[]interface{}{map[string]interface{}{"error": "name is required", "type": "bind", "meta": map[string]string{"field": "name"}}},
+		},
+		{
+			"/private", zapcore.ErrorLevel, http.StatusOK,
+			[]interface{}{map[string]interface{}{"error": "db is down", "type": "private"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			serve(r, httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+			entries := logs.TakeAll()
+			require.Len(t, entries, 1, "one entry per request")
+			assert.Equal(t, tt.level, entries[0].Level)
+			fields := entries[0].ContextMap()
+			assert.Equal(t, tt.status, fields["http_code"])
+			assert.Equal(t, http.MethodPost, fields["method"])
+			assert.Contains(t, fields, "latency")
+			assert.Equal(t, tt.errors, fields["errors"])
+		})
+	}
+}
+
+func TestEntryLevel(t *testing.T) {
+	cfg := newConfig([]Option{WithErrorLevel(func(gin.ErrorType) zapcore.Level { return zapcore.InfoLevel })})
+
+	assert.Equal(t, zapcore.WarnLevel, cfg.entryLevel(http.StatusBadGateway, []*gin.Error{{Type: gin.ErrorTypePrivate}}), "status is higher")
+	assert.Equal(t, zapcore.InfoLevel, cfg.entryLevel(http.StatusOK, []*gin.Error{{Type: gin.ErrorTypePrivate}}), "error is higher")
+	assert.Equal(t, zapcore.DebugLevel, cfg.entryLevel(http.StatusOK, nil))
+}
+
+func TestClientClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithClientClosedLevel(zapcore.DebugLevel)))
+	r.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		_ = c.Error(c.Request.Context().Err())
+		c.Status(http.StatusInternalServerError)
+	})
+	r.GET("/done", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	serve(r, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	serve(r, httptest.NewRequest(http.MethodGet, "/done", nil))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zapcore.DebugLevel, entries[0].Level, "not a server error")
+	assert.Equal(t, int64(StatusClientClosedRequest), entries[0].ContextMap()["http_code"])
+	assert.Equal(t, true, entries[0].ContextMap()["client_closed"])
+	assert.NotContains(t, entries[1].ContextMap(), "client_closed")
+}
@@ -0,0 +1,100 @@
+package ginlog
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Extra selects request metadata logged in addition to the default fields
+type Extra int
+
+const (
+	ExtraRequestSize   Extra = 1 << iota // request_size: Content-Length of the request, -1 if unknown
+	ExtraResponseSize                    // response_size: bytes written to the response body
+	ExtraProto                           // proto: HTTP/1.1, HTTP/2.0
+	ExtraHost                            // host: Host header of the request
+	ExtraReferer                         // referer
+	ExtraHandler                         // handler: name of the last handler, see gin.Context.HandlerName; Timed ones by their own name
+	ExtraContentType                     // content_type: Content-Type of the request
+	ExtraClientAborted                   // client_aborted: client went away before the response was complete
+	ExtraRemoteIP                        // remote_ip: peer address, ip field honours gin trusted proxies instead
+	ExtraForwardedFor                    // forwarded_for: X-Forwarded-For chain as sent
+
+	// AllExtra logs every metadata field
+	AllExtra = ExtraRequestSize | ExtraResponseSize | ExtraProto | ExtraHost | ExtraReferer | ExtraHandler |
+		ExtraContentType | ExtraClientAborted | ExtraRemoteIP | ExtraForwardedFor
+)
+
+// WithExtra logs selected request metadata, e.g. WithExtra(ginlog.ExtraRequestSize|ginlog.ExtraHandler).
+// Field ip is always logged as gin.Context.ClientIP resolves it: forwarding headers are taken into account
+// only for proxies trusted with gin.Engine.SetTrustedProxies
+func WithExtra(extra Extra) Option {
+	return optionFunc(func(c *config) {
+		c.extra |= extra
+	})
+}
+
+func (cfg *config) extraFields(c *gin.Context) []zap.Field {
+	var fields []zap.Field
+	add := func(e Extra, f func() zap.Field) {
+		if cfg.extra&e != 0 {
+			fields = append(fields, f())
+		}
+	}
+
+	add(ExtraRequestSize, func() zap.Field { return zap.Int64("request_size", c.Request.ContentLength) })
+	add(ExtraResponseSize, func() zap.Field {
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		return zap.Int("response_size", size)
+	})
+	add(ExtraProto, func() zap.Field { return zap.String("proto", c.Request.Proto) })
+	add(ExtraHost, func() zap.Field { return zap.String("host", c.Request.Host) })
+	add(ExtraReferer, func() zap.Field { return zap.String("referer", c.Request.Referer()) })
+	add(ExtraHandler, func() zap.Field { return zap.String("handler", handlerName(c)) })
+	add(ExtraContentType, func() zap.Field { return zap.String("content_type", c.ContentType()) })
+	add(ExtraClientAborted, func() zap.Field { return zap.Bool("client_aborted", clientAborted(c)) })
+	add(ExtraRemoteIP, func() zap.Field { return zap.String("remote_ip", c.RemoteIP()) })
+	add(ExtraForwardedFor, func() zap.Field { return zap.Strings("forwarded_for", forwardedFor(c)) })
+
+	return fields
+}
+
+// clientClosed reports whether the client went away before the response was complete:
+// request context is cancelled and nothing or not the whole declared body was written
+func clientClosed(c *gin.Context) bool {
+	if !clientAborted(c) {
+		return false
+	}
+	if !c.Writer.Written() {
+		return true
+	}
+	declared, err := strconv.Atoi(c.Writer.Header().Get("Content-Length"))
+
+	return err == nil && c.Writer.Size() < declared
+}
+
+// clientAborted reports whether request context was cancelled by the server because the client went away
+func clientAborted(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.Canceled)
+}
+
+func forwardedFor(c *gin.Context) []string {
+	var chain []string
+	for _, header := range c.Request.Header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(header, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+
+	return chain
+}
@@ -0,0 +1,76 @@
+package ginlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createClient(c *gin.Context) {
+	c.String(http.StatusCreated, "created")
+}
+
+func TestWithExtra(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.1"}))
+	r.Use(New(WithExtra(AllExtra)))
+	r.POST("/clients", createClient)
+
+	req := httptest.NewRequest(http.MethodPost, "/clients", strings.NewReader(`{"a":1}`))
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Host = "api.local"
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Referer", "https://app.local/form")
+	req.Header.Add("X-Forwarded-For", "203.0.113.7, 192.168.1.1")
+	req.Header.Add("X-Forwarded-For", "10.0.0.9")
+	serve(r, req)
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, int64(7), fields["request_size"])
+	assert.Equal(t, int64(7), fields["response_size"])
+	assert.Equal(t, "HTTP/1.1", fields["proto"])
+	assert.Equal(t, "api.local", fields["host"])
+	assert.Equal(t, "https://app.local/form", fields["referer"])
+	assert.Equal(t, "libs/logger/instrumentation/ginlog.createClient", fields["handler"])
+	assert.Equal(t, "application/json", fields["content_type"])
+	assert.Equal(t, false, fields["client_aborted"])
+	assert.Equal(t, "10.0.0.1", fields["remote_ip"])
+	assert.Equal(t, []interface{}{"203.0.113.7", "192.168.1.1", "10.0.0.9"}, fields["forwarded_for"])
+	assert.Equal(t, "192.168.1.1", fields["ip"], "nearest untrusted hop of forwarding header sent by trusted proxy")
+
+	req = httptest.NewRequest(http.MethodPost, "/clients", nil)
+	req.RemoteAddr = "172.16.0.5:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	serve(r, req.WithContext(ctx))
+
+	fields = logs.All()[1].ContextMap()
+	assert.Equal(t, "172.16.0.5", fields["ip"], "untrusted proxy can't spoof ip")
+	assert.Equal(t, true, fields["client_aborted"])
+}
+
+func TestExtraIsOptIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithExtra(ExtraHost)))
+	r.GET("/", func(c *gin.Context) {})
+	serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	fields := logs.All()[0].ContextMap()
+	assert.Contains(t, fields, "host")
+	assert.NotContains(t, fields, "request_size")
+	assert.NotContains(t, fields, "forwarded_for")
+}
@@ -0,0 +1,82 @@
+package ginlog
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxIDLength limits inbound process_id/request_id unless changed by WithIDFormat
+	DefaultMaxIDLength = 128
+	// maxRejectedID limits logged part of a rejected id
+	maxRejectedID = 64
+)
+
+// IDFormat reports whether an inbound process_id/request_id is acceptable
+type IDFormat func(id string) bool
+
+var (
+	safeID = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+	ulidID = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+)
+
+// SafeFormat accepts ids of letters, digits and ".", "_", ":", "-", used by default
+func SafeFormat(id string) bool {
+	return safeID.MatchString(id)
+}
+
+// UUIDFormat accepts canonical UUIDs: 8-4-4-4-12 hex digits
+func UUIDFormat(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// ULIDFormat accepts ULIDs: 26 characters of Crockford's base32
+func ULIDFormat(id string) bool {
+	return ulidID.MatchString(id)
+}
+
+// RegexpFormat accepts ids matching expr, it panics if expr is invalid
+func RegexpFormat(expr string) IDFormat {
+	re := regexp.MustCompile(expr)
+	return re.MatchString
+}
+
+// WithIDFormat sets format and maximum length (DefaultMaxIDLength if maxLen <= 0) of process_id and request_id
+// taken from request headers. An invalid id is replaced with a generated one, the value sent is logged
+// sanitized and truncated as rejected_process_id/rejected_request_id. SafeFormat is used by default
+func WithIDFormat(format IDFormat, maxLen int) Option {
+	return optionFunc(func(c *config) {
+		c.idFormat = format
+		c.maxIDLen = maxLen
+		if maxLen <= 0 {
+			c.maxIDLen = DefaultMaxIDLength
+		}
+	})
+}
+
+// inboundID returns id sent in header if it is valid, a new one otherwise with field recording the rejected value
+func (cfg *config) inboundID(sent, rejectedKey string) (string, []zap.Field) {
+	if sent == "" {
+		return uuid.NewString(), nil
+	}
+	if len(sent) <= cfg.maxIDLen && cfg.idFormat(sent) {
+		return sent, nil
+	}
+
+	return uuid.NewString(), []zap.Field{zap.String(rejectedKey, sanitizeID(sent))}
+}
+
+// sanitizeID truncates id and escapes everything but printable ASCII, so it can't forge log lines
+func sanitizeID(id string) string {
+	suffix := ""
+	if len(id) > maxRejectedID {
+		id, suffix = id[:maxRejectedID], "..."
+	}
+	quoted := strconv.QuoteToASCII(id)
+
+	return quoted[1:len(quoted)-1] + suffix
+}
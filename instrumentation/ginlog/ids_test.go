@@ -0,0 +1,81 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"libs/logger"
+)
+
+func TestIDFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format IDFormat
+		valid  []string
+		broken []string
+	}{
+		{"safe", SafeFormat, []string{"abc-1", "ESB:2022.01_x"}, []string{"a b", "a\nb", "<script>", ""}},
+		{"uuid", UUIDFormat, []string{"3f2504e0-4f89-11d3-9a0c-0305e82c3301"}, []string{"3f2504e04f8911d39a0c0305e82c3301", "abc-1"}},
+		{"ulid", ULIDFormat, []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV"}, []string{"01ARZ3NDEKTSV4RRFFQ69G5FAU1", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAI"}},
+		{"regexp", RegexpFormat(`^esb-\d+$`), []string{"esb-1"}, []string{"esb-x", "xesb-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, id := range tt.valid {
+				assert.True(t, tt.format(id), id)
+			}
+			for _, id := range tt.broken {
+				assert.False(t, tt.format(id), id)
+			}
+		})
+	}
+}
+
+func TestInboundIDValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithIDFormat(UUIDFormat, 0)))
+	r.GET("/", func(c *gin.Context) {})
+
+	valid := uuid.NewString()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(logger.HTTPHeaderProcessID, valid)
+	req.Header.Set(logger.HTTPHeaderRequestID, "\"}\n{\"level\":\"error\""+strings.Repeat("x", 1<<20))
+	w := serve(r, req)
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, valid, fields["process_id"])
+	assert.NotContains(t, fields, "rejected_process_id")
+
+	requestID := fields["request_id"].(string)
+	assert.True(t, UUIDFormat(requestID), "replaced with generated id")
+	assert.Equal(t, requestID, w.Header().Get(logger.HTTPHeaderRequestID))
+	rejected := fields["rejected_request_id"].(string)
+	assert.True(t, strings.HasPrefix(rejected, `\"}\n{\"level\":\"error\"xx`), rejected)
+	assert.True(t, strings.HasSuffix(rejected, "x..."))
+	assert.NotContains(t, rejected, "\n")
+	assert.Less(t, len(rejected), 100)
+}
+
+func TestMaxIDLength(t *testing.T) {
+	cfg := newConfig([]Option{WithIDFormat(SafeFormat, 4)})
+
+	id, rejected := cfg.inboundID("abcd", "rejected")
+	assert.Equal(t, "abcd", id)
+	assert.Empty(t, rejected)
+
+	id, rejected = cfg.inboundID("abcde", "rejected")
+	assert.NotEqual(t, "abcde", id)
+	assert.Len(t, rejected, 1)
+}
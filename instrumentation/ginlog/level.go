@@ -0,0 +1,54 @@
+package ginlog
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// StatusClientClosedRequest is logged instead of response status when the client went away
+// before the response was complete, as nginx does
+const StatusClientClosedRequest = 499
+
+// LevelFunc chooses level of a request entry by response status
+type LevelFunc func(status int) zapcore.Level
+
+// DefaultLevel logs 4xx and 5xx at warn, 3xx at info and everything else at debug
+func DefaultLevel(status int) zapcore.Level {
+	switch {
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	case status >= http.StatusMultipleChoices:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// ErrorLevelFunc chooses level of a request entry by type of an error added with c.Error
+type ErrorLevelFunc func(t gin.ErrorType) zapcore.Level
+
+// DefaultErrorLevel logs requests with bind and public errors at warn, with other errors at error
+func DefaultErrorLevel(t gin.ErrorType) zapcore.Level {
+	switch {
+	case t == gin.ErrorTypeAny:
+		return zapcore.ErrorLevel
+	case t&(gin.ErrorTypeBind|gin.ErrorTypePublic) != 0:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// entryLevel is the highest of status level and levels of errs
+func (cfg *config) entryLevel(status int, errs []*gin.Error) zapcore.Level {
+	level := cfg.level(status)
+	for _, e := range errs {
+		if l := cfg.errorLevel(e.Type); l > level {
+			level = l
+		}
+	}
+
+	return level
+}
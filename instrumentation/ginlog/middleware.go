@@ -1,16 +1,12 @@
 package ginlog
 
 import (
-	"net"
+	"context"
 	"net/http"
-	"net/http/httputil"
-	"os"
-	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -19,132 +15,175 @@ import (
 
 // Log returns a gin.HandlerFunc (middleware) that logs requests using uber-go/zap.
 //
-// Requests with errors are logged using zap.Error().
-// Requests without errors are logged using zap.Info().
-//
-// It receives:
-//  1. A time package format string (e.g. time.RFC3339).
-//  2. A boolean stating whether to use UTC time zone or local.
+// Every request is logged once, at level chosen by DefaultLevel and DefaultErrorLevel
+// for errors added with c.Error.
 func Log() gin.HandlerFunc {
-	return LogExcept(nil)
+	return New()
 }
 
 // LogExcept returns a gin.HandlerFunc with logging except routes in skipPath
 func LogExcept(skipPath []string) gin.HandlerFunc {
-	skipPaths := make(map[string]bool, len(skipPath))
-	for _, path := range skipPath {
-		skipPaths[path] = true
-	}
-
-	logFunc := func(l logger.Logger, httpCode int) func(string, ...zap.Field) {
-		switch {
-		case httpCode >= http.StatusBadRequest:
-			return l.Warn
-		case httpCode >= http.StatusMultipleChoices:
-			return l.Info
-		default:
-			return l.Debug
-		}
-	}
+	return New(Skip(skipPath...))
+}
+
+// New returns a gin.HandlerFunc (middleware) that binds process_id/request_id taken from request headers
+// (or generated) to the request context and logs every request once handlers are done
+func New(opts ...Option) gin.HandlerFunc {
+	cfg := newConfig(opts)
 
 	return func(c *gin.Context) {
 		start := time.Now()
-		processID := c.GetHeader(logger.HTTPHeaderProcessID)
-		if processID == "" {
-			processID = uuid.NewString()
-		}
+		processID, rejected := cfg.inboundID(c.GetHeader(cfg.processIDHeader), "rejected_process_id")
 		ctx := logger.BindProcessID(c.Request.Context(), processID)
 
-		requestID := c.GetHeader(logger.HTTPHeaderRequestID)
-		if requestID == "" {
-			requestID = uuid.NewString()
-		}
+		requestID, rejectedReq := cfg.inboundID(c.GetHeader(cfg.requestIDHeader), "rejected_request_id")
 		ctx = logger.BindRequestID(ctx, requestID)
+		token, debug := cfg.debugToken(c)
+		if debug {
+			ctx = logger.BindDebug(ctx, token)
+		}
+		ctx = logger.WithCanonical(ctx)
 
 		c.Set(string(logger.ProcessIDKey), processID)
 		c.Set(string(logger.RequestIDKey), requestID)
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		if cfg.echoProcessID != "" {
+			c.Header(cfg.echoProcessID, processID)
+		}
+		if cfg.echoRequestID != "" {
+			c.Header(cfg.echoRequestID, requestID)
+		}
 		c.Request = c.Request.WithContext(ctx)
-		c.Next()
 
-		if _, ok := skipPaths[path]; !ok {
-			end := time.Now()
-			latency := end.Sub(start)
-			l := logger.FromCtx(ctx, "gin")
-			if len(c.Errors) > 0 {
-				// Append error field if this is an erroneous request.
-				for _, e := range c.Errors.Errors() {
-					l.Error(e)
-				}
-			} else {
-				httpCode := c.Writer.Status()
-				fields := []zapcore.Field{
-					zap.Int("http_code", httpCode),
-					zap.String("method", c.Request.Method),
-					zap.String("path", path),
-					zap.String("query", query),
-					zap.String("ip", c.ClientIP()),
-					zap.String("user-agent", c.Request.UserAgent()),
-					zap.Duration("latency", latency),
-				}
-				logFunc(l, httpCode)(path, fields...)
-			}
+		r := &request{
+			cfg:      cfg,
+			start:    start,
+			path:     c.Request.URL.Path,
+			route:    c.FullPath(),
+			query:    c.Request.URL.RawQuery,
+			rejected: append(rejected, rejectedReq...),
+			debug:    debug,
 		}
+		c.Set(requestKey, r)
+		r.setup(c)
+		// a panic nobody below recovers skips write
+		defer r.stopSlow()
+
+		c.Next()
+
+		r.write(c, ctx)
 	}
 }
 
-// RecoveryWithZap returns a gin.HandlerFunc (middleware)
-// that recovers from any panics and logs requests using uber-go/zap.
-// All errors are logged using zap.Error().
-// stack means whether output the stack info.
-// The stack info is easy to find where the error occurs but the stack info is too large.
-func RecoveryWithZap(stack bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				// Check for a broken connection, as it is not really a
-				// condition that warrants a panic stack trace.
-				var brokenPipe bool
-				if ne, ok := err.(*net.OpError); ok {
-					if se, ok := ne.Err.(*os.SyscallError); ok {
-						if strings.Contains(strings.ToLower(se.Error()), "broken pipe") || strings.Contains(strings.ToLower(se.Error()), "connection reset by peer") {
-							brokenPipe = true
-						}
-					}
-				}
-
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
-
-				l := logger.FromCtx(c.Request.Context(), "gin")
-				if brokenPipe {
-					l.Error(c.Request.URL.Path,
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-					// If the connection is dead, we can't write a status to it.
-					c.Error(err.(error)) // nolint: errcheck
-					c.Abort()
-					return
-				}
-
-				if stack {
-					l.Error("[Recovery from panic]",
-						zap.Time("time", time.Now()),
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-						zap.String("stack", string(debug.Stack())),
-					)
-				} else {
-					l.Error("[Recovery from panic]",
-						zap.Time("time", time.Now()),
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-				}
-				c.AbortWithStatus(http.StatusInternalServerError)
-			}
-		}()
-		c.Next()
+// requestKey keeps *request in gin.Context, so Configure can change its settings
+const requestKey = "ginlog.request"
+
+// request is the state of one logged request
+type request struct {
+	cfg      *config
+	start    time.Time
+	path     string
+	route    string
+	query    string
+	reqBody  *capture
+	respBody *bodyWriter
+	timings  *chainTimings
+	slow     *slowWatch
+	rejected []zap.Field
+	debug    bool
+}
+
+// setup starts collecting what cfg asks for. It is called again when Configure changes settings,
+// so it must not restart collecting
+func (r *request) setup(c *gin.Context) {
+	if r.respBody == nil && r.cfg.captures(r.route, r.path) {
+		r.reqBody = captureRequest(c, r.cfg.bodyLimit)
+		r.respBody = &bodyWriter{ResponseWriter: c.Writer, capture: capture{limit: r.cfg.bodyLimit}}
+		c.Writer = r.respBody
+	}
+	if r.timings == nil && r.cfg.timings {
+		r.timings = &chainTimings{}
+		c.Set(timingsKey, r.timings)
+	}
+	r.watchSlow(c)
+}
+
+func (r *request) write(c *gin.Context, ctx context.Context) {
+	r.stopSlow()
+	cfg := r.cfg
+	if cfg.skipAll || cfg.skipped(r.route, r.path) {
+		return
+	}
+
+	end := time.Now()
+	latency := end.Sub(r.start)
+	httpCode := c.Writer.Status()
+	closed := clientClosed(c)
+	if closed {
+		httpCode = StatusClientClosedRequest
+	}
+	level := cfg.entryLevel(httpCode, c.Errors)
+	if closed {
+		level = cfg.closedLevel
+	}
+	level, slow := cfg.slowLevel(latency, level)
+	if !cfg.sampled(level) {
+		return
+	}
+
+	fields := []zapcore.Field{
+		zap.Int("http_code", httpCode),
+		zap.String("method", c.Request.Method),
+		zap.String("path", r.path),
+		zap.String("route", r.route),
+		zap.String("query", r.query),
+		zap.String("ip", c.ClientIP()),
+		zap.String("user-agent", c.Request.UserAgent()),
+		zap.Duration("latency", latency),
+	}
+	fields = append(fields, r.rejected...)
+	fields = append(fields, cfg.extraFields(c)...)
+	if len(cfg.headers) > 0 {
+		fields = append(fields, zap.Object("request_headers", cfg.requestHeaders(c.Request.Header)))
+	}
+	if r.respBody != nil && cfg.bodyLimit > 0 {
+		fields = append(fields, cfg.bodyFields(c, r.reqBody, r.respBody)...)
+	}
+	if len(c.Errors) > 0 {
+		fields = append(fields, zap.Array("errors", ginErrors(c.Errors)))
+	}
+	if r.timings != nil && cfg.timings {
+		fields = append(fields, r.timings.fields()...)
 	}
+	for _, f := range cfg.fields {
+		fields = append(fields, f(c)...)
+	}
+	fields = append(fields, logger.CanonicalFields(ctx)...)
+	if closed {
+		fields = append(fields, zap.Bool("client_closed", true))
+	}
+	if slow {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+	if r.debug {
+		fields = append(fields, zap.Bool("debug", true))
+	}
+
+	l := logger.FromCtx(ctx, cfg.namespace)
+	if ce := l.Check(level, r.path); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// requestHeaders returns configured headers of h with sensitive values masked
+func (cfg *config) requestHeaders(h http.Header) zapcore.ObjectMarshaler {
+	masked := cfg.redact.Header(h)
+
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for _, name := range cfg.headers {
+			if values := masked.Values(name); len(values) > 0 {
+				enc.AddString(http.CanonicalHeaderKey(name), strings.Join(values, ", "))
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,184 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"libs/logger"
+)
+
+// observe replaces global logger with one recording entries of every level
+func observe(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger.New("debug", "test", zap.WrapCore(func(zapcore.Core) zapcore.Core { return core }))
+	t.Cleanup(func() {
+		logger.New("debug", "test", zap.WrapCore(func(zapcore.Core) zapcore.Core { return zapcore.NewNopCore() }))
+	})
+
+	return logs
+}
+
+func serve(r *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestNew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(
+		Skip("/health"),
+		WithLevel(func(status int) zapcore.Level {
+			if status == http.StatusTeapot {
+				return zapcore.ErrorLevel
+			}
+			return DefaultLevel(status)
+		}),
+		WithIDHeaders("X-Correlation-Id", "X-Request-Id"),
+		WithRequestHeaders("Authorization", "X-Client"),
+		WithNamespace("api"),
+		WithFields(func(c *gin.Context) []zap.Field {
+			return []zap.Field{zap.String("client", c.GetHeader("X-Client"))}
+		}),
+	))
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/tea", func(c *gin.Context) {
+		assert.Equal(t, "p-1", logger.GetProcessID(c.Request.Context()))
+		c.Status(http.StatusTeapot)
+	})
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Zero(t, logs.Len(), "skipped path")
+
+	req := httptest.NewRequest(http.MethodGet, "/tea?x=1", nil)
+	req.Header.Set("X-Correlation-Id", "p-1")
+	req.Header.Set("X-Request-Id", "r-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Client", "mobile")
+	serve(r, req)
+
+	require.Equal(t, 1, logs.Len())
+	e := logs.All()[0]
+	assert.Equal(t, zapcore.ErrorLevel, e.Level)
+	assert.Equal(t, "test.api", e.LoggerName)
+	assert.Equal(t, "/tea", e.Message)
+	fields := e.ContextMap()
+	assert.Equal(t, "r-1", fields["request_id"])
+	assert.Equal(t, "p-1", fields["process_id"])
+	assert.Equal(t, int64(http.StatusTeapot), fields["http_code"])
+	assert.Equal(t, "x=1", fields["query"])
+	assert.Equal(t, "mobile", fields["client"])
+	assert.Equal(t, map[string]interface{}{"Authorization": logger.RedactedValue, "X-Client": "mobile"}, fields["request_headers"])
+}
+
+func TestLogExcept(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(LogExcept([]string{"/metrics"}))
+	r.GET("/metrics", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/clients", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	serve(r, httptest.NewRequest(http.MethodGet, "/clients", nil))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	assert.Equal(t, "test.gin", logs.All()[0].LoggerName)
+	assert.NotEmpty(t, logs.All()[0].ContextMap()["request_id"], "generated")
+}
+
+func TestSkipPatterns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(Skip("/swagger/*any", "/static/*.js", "/internal/**", "/exact")))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/swagger/*any", ok)
+	r.GET("/static/:file", ok)
+	r.GET("/internal/*rest", ok)
+	r.GET("/exact", ok)
+	r.GET("/clients/:id", ok)
+
+	for _, path := range []string{"/swagger/index.html", "/swagger/a/b.json", "/static/app.js", "/internal", "/internal/a/b", "/exact"} {
+		serve(r, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Zero(t, logs.Len(), path)
+	}
+
+	for _, path := range []string{"/static/app.css", "/internals", "/clients/1", "/clients/2", "/unknown"} {
+		serve(r, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	routes := make([]interface{}, 0, logs.Len())
+	for _, e := range logs.All() {
+		routes = append(routes, e.ContextMap()["route"])
+	}
+	assert.Equal(t, []interface{}{"/static/:file", "", "/clients/:id", "/clients/:id", ""}, routes)
+}
+
+func TestResponseIDHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe(t)
+
+	tests := []struct {
+		name                 string
+		opts                 []Option
+		processID, requestID string
+	}{
+		{"default", nil, logger.HTTPHeaderProcessID, logger.HTTPHeaderRequestID},
+		{"request header names", []Option{WithIDHeaders("X-Correlation-Id", "X-Request-Id")}, "X-Correlation-Id", "X-Request-Id"},
+		{"request id only", []Option{WithResponseIDHeaders("", "X-Request-Id")}, "", "X-Request-Id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(New(tt.opts...))
+			r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(logger.HTTPHeaderProcessID, "p-1")
+			req.Header.Set("X-Correlation-Id", "p-1")
+			w := serve(r, req)
+
+			if tt.processID != "" {
+				assert.Equal(t, "p-1", w.Header().Get(tt.processID))
+			} else {
+				assert.Empty(t, w.Header().Get(logger.HTTPHeaderProcessID))
+			}
+			assert.NotEmpty(t, w.Header().Get(tt.requestID), "generated request_id is returned")
+		})
+	}
+}
+
+func TestCanonicalFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New())
+	r.POST("/decisions", func(c *gin.Context) {
+		logger.AddToCanonical(c.Request.Context(), logger.ProspectID("p-1"), zap.String("decision", "approve"))
+		c.Status(http.StatusCreated)
+	})
+	serve(r, httptest.NewRequest(http.MethodPost, "/decisions", nil))
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "p-1", fields["prospect_id"])
+	assert.Equal(t, "approve", fields["decision"])
+	assert.Equal(t, int64(http.StatusCreated), fields["http_code"])
+}
@@ -0,0 +1,197 @@
+package ginlog
+
+import (
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+// Option configures middleware created by New
+type Option interface {
+	Apply(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) Apply(c *config) {
+	f(c)
+}
+
+// FieldsFunc returns extra fields of a request entry. It is called after handlers, so it sees response status
+type FieldsFunc func(c *gin.Context) []zap.Field
+
+type config struct {
+	namespace       string
+	skip            []string
+	skipAll         bool
+	minLevel        zapcore.Level
+	sampleRate      float64
+	level           LevelFunc
+	errorLevel      ErrorLevelFunc
+	closedLevel     zapcore.Level
+	processIDHeader string
+	requestIDHeader string
+	echoProcessID   string
+	echoRequestID   string
+	echoSet         bool
+	idFormat        IDFormat
+	maxIDLen        int
+	debugSecret     []byte
+	debugNets       []*net.IPNet
+	debugLifetime   time.Duration
+	headers         []string
+	redact          *logger.Redactor
+	fields          []FieldsFunc
+	extra           Extra
+	timings         bool
+	slow            time.Duration
+	slowStack       bool
+	bodyLimit       int
+	bodyRoutes      []string
+	bodyTypes       []string
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		namespace:       "gin",
+		level:           DefaultLevel,
+		errorLevel:      DefaultErrorLevel,
+		closedLevel:     zapcore.InfoLevel,
+		minLevel:        zapcore.DebugLevel,
+		sampleRate:      1,
+		processIDHeader: logger.HTTPHeaderProcessID,
+		requestIDHeader: logger.HTTPHeaderRequestID,
+		idFormat:        SafeFormat,
+		maxIDLen:        DefaultMaxIDLength,
+		debugLifetime:   logger.DefaultDebugTokenLifetime,
+		redact:          logger.DefaultRedactor(),
+		bodyTypes:       DefaultBodyContentTypes,
+	}
+	for i := range opts {
+		opts[i].Apply(c)
+	}
+	if !c.echoSet {
+		c.echoProcessID, c.echoRequestID = c.processIDHeader, c.requestIDHeader
+	}
+
+	return c
+}
+
+// Skip disables request entries of paths, process_id/request_id are still bound to the context.
+// A pattern is compared with both gin route template (c.FullPath) and request path:
+//
+//	"/swagger/*any"     route template, any request routed to it
+//	"/clients/list"     exact path
+//	"/static/*.js"      glob, see path.Match
+//	"/internal/**"      prefix: "/internal" and everything below it
+func Skip(patterns ...string) Option {
+	return optionFunc(func(c *config) {
+		c.skip = append(c.skip, patterns...)
+	})
+}
+
+// skipped reports whether request routed to route by urlPath matches any Skip pattern
+func (cfg *config) skipped(route, urlPath string) bool {
+	return matchAny(cfg.skip, route, urlPath)
+}
+
+// matchAny reports whether request routed to route by urlPath matches any of patterns, see Skip
+func matchAny(patterns []string, route, urlPath string) bool {
+	for _, p := range patterns {
+		switch {
+		case p == route && route != "", p == urlPath:
+			return true
+		case strings.HasSuffix(p, "/**"):
+			prefix := strings.TrimSuffix(p, "/**")
+			if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+				return true
+			}
+		default:
+			if ok, _ := path.Match(p, urlPath); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WithLevel replaces mapping of response status to entry level, DefaultLevel is used by default
+func WithLevel(level LevelFunc) Option {
+	return optionFunc(func(c *config) {
+		c.level = level
+	})
+}
+
+// WithErrorLevel replaces mapping of c.Error types to entry level, DefaultErrorLevel is used by default.
+// Entry is logged at the highest of status level and levels of its errors
+func WithErrorLevel(level ErrorLevelFunc) Option {
+	return optionFunc(func(c *config) {
+		c.errorLevel = level
+	})
+}
+
+// WithClientClosedLevel sets level of requests the client went away from, info by default.
+// Such requests are logged with StatusClientClosedRequest and client_closed=true whatever handlers replied
+// or added with c.Error, so they don't count as server errors
+func WithClientClosedLevel(level zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.closedLevel = level
+	})
+}
+
+// WithIDHeaders sets request header names process_id and request_id are read from,
+// logger.HTTPHeaderProcessID and logger.HTTPHeaderRequestID by default
+func WithIDHeaders(processID, requestID string) Option {
+	return optionFunc(func(c *config) {
+		c.processIDHeader = processID
+		c.requestIDHeader = requestID
+	})
+}
+
+// WithResponseIDHeaders sets response header names process_id and request_id are returned to the client in,
+// so it can quote them in issue reports. Headers are set before handlers run, empty name disables a header.
+// By default names of WithIDHeaders are used
+func WithResponseIDHeaders(processID, requestID string) Option {
+	return optionFunc(func(c *config) {
+		c.echoProcessID = processID
+		c.echoRequestID = requestID
+		c.echoSet = true
+	})
+}
+
+// WithRequestHeaders logs values of request headers under "request_headers",
+// sensitive ones are masked, see WithRedactor
+func WithRequestHeaders(names ...string) Option {
+	return optionFunc(func(c *config) {
+		c.headers = append(c.headers, names...)
+	})
+}
+
+// WithRedactor replaces redaction rules of logged headers, logger.DefaultRedactor by default
+func WithRedactor(r *logger.Redactor) Option {
+	return optionFunc(func(c *config) {
+		c.redact = r
+	})
+}
+
+// WithNamespace sets logger namespace of request entries, "gin" by default
+func WithNamespace(namespace string) Option {
+	return optionFunc(func(c *config) {
+		c.namespace = namespace
+	})
+}
+
+// WithFields adds fields returned by f to every request entry
+func WithFields(f FieldsFunc) Option {
+	return optionFunc(func(c *config) {
+		c.fields = append(c.fields, f)
+	})
+}
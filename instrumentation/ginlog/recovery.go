@@ -0,0 +1,197 @@
+package ginlog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+// RecoveryWithZap returns a gin.HandlerFunc (middleware)
+// that recovers from any panics and logs requests using uber-go/zap.
+// All errors are logged using zap.Error().
+// stack means whether output the stack info: frames of the panicking goroutine
+// without runtime, gin and net/http ones.
+// Broken connections are logged without stack and nothing is written to them.
+func RecoveryWithZap(stack bool, opts ...RecoveryOption) gin.HandlerFunc {
+	rc := recoveryConfig{respond: func(c *gin.Context, _ error) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}}
+	for _, opt := range opts {
+		opt(&rc)
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			if v := recover(); v != nil {
+				err := panicError(v)
+				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				l := logger.FromCtx(c.Request.Context(), "gin")
+
+				// Check for a broken connection, as it is not really a
+				// condition that warrants a panic stack trace.
+				if brokenConnection(err) {
+					l.Error(c.Request.URL.Path,
+						zap.Error(err),
+						zap.String("request", string(httpRequest)),
+					)
+					// If the connection is dead, we can't write a status to it.
+					c.Error(err) // nolint: errcheck
+					c.Abort()
+					return
+				}
+
+				fields := []zap.Field{zap.Error(err), zap.String("request", string(httpRequest))}
+				var frames Frames
+				if stack || rc.onPanic != nil {
+					frames = callers()
+				}
+				if stack {
+					fields = append(fields, zap.Array(logger.StackTraceKey, frames))
+				}
+				l.Error("[Recovery from panic]", fields...)
+
+				if rc.onPanic != nil {
+					rc.onPanic(c, err, frames)
+				}
+				rc.respond(c, err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// RecoveryOption configures RecoveryWithZap
+type RecoveryOption func(rc *recoveryConfig)
+
+type recoveryConfig struct {
+	respond func(c *gin.Context, err error)
+	onPanic func(c *gin.Context, err error, stack Frames)
+}
+
+// ErrorResponse is JSON body of a recovered request
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RecoveryRequestID replies to recovered requests with ErrorResponse carrying request_id set by Log,
+// so clients can quote it in issue reports
+func RecoveryRequestID() RecoveryOption {
+	return RecoveryResponse(func(c *gin.Context, _ error) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     http.StatusText(http.StatusInternalServerError),
+			RequestID: c.GetString(string(logger.RequestIDKey)),
+		})
+	})
+}
+
+// RecoveryResponse replaces bare 500 written to recovered requests, respond must abort the chain
+func RecoveryResponse(respond func(c *gin.Context, err error)) RecoveryOption {
+	return func(rc *recoveryConfig) {
+		rc.respond = respond
+	}
+}
+
+// OnPanic calls f after a panic is logged and before the response is written, e.g. to count panics or
+// report them to an error tracker. Broken connections are not reported
+func OnPanic(f func(c *gin.Context, err error, stack Frames)) RecoveryOption {
+	return func(rc *recoveryConfig) {
+		rc.onPanic = f
+	}
+}
+
+// panicError converts recovered value to error, keeping errors as they are
+func panicError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+
+	return fmt.Errorf("panic: %v", v)
+}
+
+// brokenConnection reports whether err is a write to a connection closed by the client
+func brokenConnection(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Frame is a call of panicking goroutine stack
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+func (f Frame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+
+	return nil
+}
+
+// Frames is stack from the panic site outwards
+type Frames []Frame
+
+func (fs Frames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range fs {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// internalFrames are prefixes of functions skipped in stack: panic machinery, this middleware and request dispatching
+var internalFrames = []string{
+	"runtime.",
+	recoveryFunc(),
+	"github.com/gin-gonic/gin.",
+	"net/http.",
+}
+
+// recoveryFunc names RecoveryWithZap and its closures, package path is taken from another function
+// as RecoveryWithZap itself can't be referenced here
+func recoveryFunc() string {
+	name := runtime.FuncForPC(reflect.ValueOf(panicError).Pointer()).Name()
+
+	return name[:strings.LastIndexByte(name, '.')+1] + "RecoveryWithZap"
+}
+
+func callers() Frames {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(2, pc)
+	it := runtime.CallersFrames(pc[:n])
+
+	var frames Frames
+	for {
+		f, more := it.Next()
+		if !internal(f.Function) {
+			frames = append(frames, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			return frames
+		}
+	}
+}
+
+func internal(function string) bool {
+	for _, prefix := range internalFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
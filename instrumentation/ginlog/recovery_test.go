@@ -0,0 +1,100 @@
+package ginlog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+func TestRecoveryWithZap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	var reported error
+	var reportedStack Frames
+	r := gin.New()
+	r.Use(RecoveryWithZap(true,
+		OnPanic(func(c *gin.Context, err error, stack Frames) { reported, reportedStack = err, stack }),
+		RecoveryResponse(func(c *gin.Context, err error) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+		}),
+	))
+	r.GET("/string", func(c *gin.Context) { panicHere("boom") })
+	r.GET("/pipe", func(c *gin.Context) {
+		panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	})
+	r.GET("/reset", func(c *gin.Context) { panic(fmt.Errorf("copy: %w", syscall.ECONNRESET)) })
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/string", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"message":"panic: boom"}`, w.Body.String())
+	assert.EqualError(t, reported, "panic: boom")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.NotContains(t, fields, "time")
+	stack, ok := fields["stack"].([]interface{})
+	require.True(t, ok, "structured stack")
+	top := stack[0].(map[string]interface{})
+	assert.True(t, strings.HasSuffix(top["function"].(string), "ginlog.panicHere"), "panic site first: %v", top)
+	assert.True(t, strings.HasSuffix(top["file"].(string), "recovery_test.go"))
+	assert.NotZero(t, top["line"])
+	assert.Len(t, reportedStack, len(stack))
+	for _, f := range reportedStack {
+		assert.False(t, strings.HasPrefix(f.Function, "runtime."), f.Function)
+		assert.False(t, strings.HasPrefix(f.Function, "github.com/gin-gonic/gin."), f.Function)
+		assert.False(t, strings.HasPrefix(f.Function, recoveryFunc()), f.Function)
+	}
+
+	for _, path := range []string{"/pipe", "/reset"} {
+		reported = nil
+		w := serve(r, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Empty(t, w.Body.String(), path)
+		assert.Nil(t, reported, "broken connections are not reported")
+		entries := logs.TakeAll()
+		require.Len(t, entries, 1)
+		assert.NotContains(t, entries[0].ContextMap(), "stack")
+	}
+}
+
+func panicHere(v interface{}) {
+	panic(v)
+}
+
+func TestPanicError(t *testing.T) {
+	err := errors.New("boom")
+	assert.Equal(t, err, panicError(err))
+	assert.EqualError(t, panicError(42), "panic: 42")
+}
+
+func TestRecoveryRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe(t)
+
+	r := gin.New()
+	r.Use(New(), RecoveryWithZap(false, RecoveryRequestID()))
+	r.GET("/", func(c *gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(logger.HTTPHeaderRequestID, "r-1")
+	w := serve(r, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"Internal Server Error","request_id":"r-1"}`, w.Body.String())
+	assert.Equal(t, "r-1", w.Header().Get(logger.HTTPHeaderRequestID))
+}
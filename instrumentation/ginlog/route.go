@@ -0,0 +1,85 @@
+package ginlog
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// Configure returns a gin.HandlerFunc changing log settings of requests it handles, so settings can be
+// attached to a gin.RouterGroup or a single route:
+//
+//	payments := r.Group("/payments", ginlog.Configure(ginlog.WithLevel(ginlog.FixedLevel(zapcore.InfoLevel)), ginlog.CaptureBodies(64<<10)))
+//	r.GET("/status", ginlog.Configure(ginlog.MinLevel(zapcore.WarnLevel)), status)
+//
+// opts are applied on top of New settings, Configure handlers of outer groups first.
+// Options which act before routing (WithIDHeaders, WithResponseIDHeaders) have no effect here.
+// Requests not logged by New are left as they are.
+// Settings are built once for each configuration they are applied on top of, not on every request
+func Configure(opts ...Option) gin.HandlerFunc {
+	var configs sync.Map // *config to its copy with opts applied
+
+	return func(c *gin.Context) {
+		if r, ok := c.Value(requestKey).(*request); ok {
+			cfg, ok := configs.Load(r.cfg)
+			if !ok {
+				cfg, _ = configs.LoadOrStore(r.cfg, r.cfg.with(opts))
+			}
+			r.cfg = cfg.(*config)
+			r.setup(c)
+		}
+		c.Next()
+	}
+}
+
+// with returns a copy of cfg with opts applied, cfg is not modified
+func (cfg *config) with(opts []Option) *config {
+	c := *cfg
+	c.skip = append([]string(nil), cfg.skip...)
+	c.headers = append([]string(nil), cfg.headers...)
+	c.fields = append([]FieldsFunc(nil), cfg.fields...)
+	for i := range opts {
+		opts[i].Apply(&c)
+	}
+
+	return &c
+}
+
+// FixedLevel logs every request at level
+func FixedLevel(level zapcore.Level) LevelFunc {
+	return func(int) zapcore.Level {
+		return level
+	}
+}
+
+// MinLevel drops entries below level, e.g. MinLevel(zapcore.WarnLevel) logs failed requests only
+func MinLevel(level zapcore.Level) Option {
+	return optionFunc(func(c *config) {
+		c.minLevel = level
+	})
+}
+
+// Sample logs rate (0..1) share of requests randomly chosen, entries at warn and above are always logged
+func Sample(rate float64) Option {
+	return optionFunc(func(c *config) {
+		c.sampleRate = rate
+	})
+}
+
+// SkipAll disables request entries, e.g. of a route group
+func SkipAll() Option {
+	return optionFunc(func(c *config) {
+		c.skipAll = true
+	})
+}
+
+// sampled reports whether entry of level is written according to MinLevel and Sample
+func (cfg *config) sampled(level zapcore.Level) bool {
+	if level < cfg.minLevel {
+		return false
+	}
+
+	return level >= zapcore.WarnLevel || cfg.sampleRate >= 1 || rand.Float64() < cfg.sampleRate
+}
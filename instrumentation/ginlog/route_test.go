@@ -0,0 +1,89 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConfigure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithRequestHeaders("X-Client")))
+	status := func(code int) gin.HandlerFunc {
+		return func(c *gin.Context) { c.String(code, `{"ok":true}`) }
+	}
+
+	payments := r.Group("/payments", Configure(WithLevel(FixedLevel(zapcore.InfoLevel)), CaptureBodies(1024)))
+	payments.POST("", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+		c.String(http.StatusCreated, `{"id":1}`)
+	})
+	payments.GET("/:id/poll", Configure(MinLevel(zapcore.WarnLevel)), status(http.StatusOK))
+
+	r.GET("/poll", Configure(MinLevel(zapcore.WarnLevel)), status(http.StatusOK))
+	r.GET("/poll-failed", Configure(MinLevel(zapcore.WarnLevel)), status(http.StatusBadGateway))
+	r.GET("/health", Configure(SkipAll()), status(http.StatusInternalServerError))
+	r.GET("/sampled", Configure(Sample(0)), status(http.StatusOK))
+	r.GET("/plain", status(http.StatusOK))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	serve(r, req)
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, `{"amount":1}`, entries[0].ContextMap()["request_dump"], "body capture enabled by group")
+	assert.Equal(t, `{"id":1}`, entries[0].ContextMap()["response_dump"])
+
+	for _, path := range []string{"/payments/1/poll", "/poll", "/health", "/sampled"} {
+		serve(r, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Zero(t, logs.Len(), path)
+	}
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/poll-failed", nil))
+	serve(r, httptest.NewRequest(http.MethodGet, "/plain", nil))
+	entries = logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/poll-failed", entries[0].Message)
+	assert.Equal(t, "/plain", entries[1].Message)
+	assert.Equal(t, zapcore.DebugLevel, entries[1].Level, "other routes keep New settings")
+	assert.NotContains(t, entries[1].ContextMap(), "response_dump")
+}
+
+func TestConfigureDoesNotChangeNewSettings(t *testing.T) {
+	cfg := newConfig([]Option{Skip("/a", "/b"), WithRequestHeaders("X-A")})
+	cfg.skip = cfg.skip[:1] // leave capacity to catch appends into shared array
+
+	route := cfg.with([]Option{Skip("/c"), WithRequestHeaders("X-B")})
+
+	assert.Equal(t, []string{"/a"}, cfg.skip)
+	assert.Equal(t, []string{"X-A"}, cfg.headers)
+	assert.Equal(t, []string{"/a", "/c"}, route.skip)
+	assert.Equal(t, []string{"X-A", "X-B"}, route.headers)
+}
+
+func TestConfigureBuildsOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe(t)
+
+	applied := 0
+	r := gin.New()
+	r.Use(New())
+	r.GET("/login", Configure(RedactFields("password"), optionFunc(func(*config) { applied++ })), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for i := 0; i < 3; i++ {
+		serve(r, httptest.NewRequest(http.MethodGet, "/login", nil))
+	}
+
+	assert.Equal(t, 1, applied, "options are not applied on every request")
+}
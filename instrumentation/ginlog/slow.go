@@ -0,0 +1,120 @@
+package ginlog
+
+import (
+	"bytes"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+// maxSlowStack limits dump of all goroutines taken to find the handler one
+const maxSlowStack = 8 << 20
+
+// SlowThreshold logs requests taking longer than d at warn or above with slow=true.
+// Set it for a group or a route with Configure
+func SlowThreshold(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.slow = d
+	})
+}
+
+// SlowStack logs stack of the handler goroutine as soon as it runs past SlowThreshold, in a separate
+// warn entry "slow request in progress", so hanging requests are visible before they finish.
+// Stack of every goroutine is taken to find it, so keep the threshold well above usual latency
+func SlowStack() Option {
+	return optionFunc(func(c *config) {
+		c.slowStack = true
+	})
+}
+
+// slowWatch dumps stack of the handler goroutine once the threshold is passed
+type slowWatch struct {
+	threshold time.Duration
+	timer     *time.Timer
+}
+
+// watchSlow starts (or restarts with a new threshold) the watch of r
+func (r *request) watchSlow(c *gin.Context) {
+	threshold := r.cfg.slow
+	if !r.cfg.slowStack || threshold <= 0 {
+		threshold = 0
+	}
+	if r.slow != nil && r.slow.threshold == threshold {
+		return
+	}
+	r.stopSlow()
+	if threshold == 0 {
+		return
+	}
+
+	gid := goroutineID()
+	l := logger.FromCtx(c.Request.Context(), r.cfg.namespace)
+	path, route, start := r.path, r.route, r.start
+	r.slow = &slowWatch{threshold: threshold}
+	r.slow.timer = time.AfterFunc(threshold-time.Since(start), func() {
+		l.Warn("slow request in progress",
+			zap.String("path", path),
+			zap.String("route", route),
+			zap.Duration("elapsed", time.Since(start)),
+			zap.ByteString(logger.StackTraceKey, goroutineStack(gid)),
+		)
+	})
+}
+
+func (r *request) stopSlow() {
+	if r.slow != nil {
+		r.slow.timer.Stop()
+		r.slow = nil
+	}
+}
+
+// slowLevel raises level of a request slower than threshold
+func (cfg *config) slowLevel(latency time.Duration, level zapcore.Level) (zapcore.Level, bool) {
+	if cfg.slow <= 0 || latency <= cfg.slow {
+		return level, false
+	}
+	if level < zapcore.WarnLevel {
+		level = zapcore.WarnLevel
+	}
+
+	return level, true
+}
+
+// goroutineID parses id of the current goroutine from its stack header "goroutine 42 [running]:"
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		return append([]byte(nil), buf[:i]...)
+	}
+
+	return nil
+}
+
+// goroutineStack returns stack of goroutine gid from dump of all goroutines
+func goroutineStack(gid []byte) []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxSlowStack {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	header := append(append([]byte("goroutine "), gid...), ' ')
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(g, header) {
+			return g
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func stuckHandler(c *gin.Context) {
+	time.Sleep(100 * time.Millisecond)
+	c.Status(http.StatusOK)
+}
+
+func TestSlowRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(SlowThreshold(time.Second), SlowStack()))
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/stuck", Configure(SlowThreshold(20*time.Millisecond)), stuckHandler)
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	assert.NotContains(t, entries[0].ContextMap(), "slow")
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/stuck", nil))
+	entries = logs.TakeAll()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "slow request in progress", entries[0].Message)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "/stuck", entries[0].ContextMap()["route"])
+	stack := entries[0].ContextMap()["stack"].(string)
+	assert.True(t, strings.HasPrefix(stack, "goroutine "), stack)
+	assert.Contains(t, stack, "ginlog.stuckHandler", "stack of the handler goroutine")
+
+	assert.Equal(t, "/stuck", entries[1].Message)
+	assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	assert.Equal(t, true, entries[1].ContextMap()["slow"])
+}
+
+func TestSlowWatchStopsOnPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(SlowThreshold(20*time.Millisecond), SlowStack()))
+	r.GET("/", func(c *gin.Context) { panic("boom") })
+
+	assert.Panics(t, func() { serve(r, httptest.NewRequest(http.MethodGet, "/", nil)) }, "not recovered below New")
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, logs.FilterMessage("slow request in progress").Len(), "finished request is not reported")
+}
+
+func TestSlowLevel(t *testing.T) {
+	cfg := newConfig([]Option{SlowThreshold(time.Second)})
+
+	level, slow := cfg.slowLevel(2*time.Second, zapcore.DebugLevel)
+	assert.Equal(t, zapcore.WarnLevel, level)
+	assert.True(t, slow)
+	level, _ = cfg.slowLevel(2*time.Second, zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.ErrorLevel, level, "higher level is kept")
+	level, slow = cfg.slowLevel(time.Second, zapcore.DebugLevel)
+	assert.Equal(t, zapcore.DebugLevel, level)
+	assert.False(t, slow)
+}
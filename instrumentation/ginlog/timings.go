@@ -0,0 +1,150 @@
+package ginlog
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// timingsKey keeps *chainTimings of a request in gin.Context
+const timingsKey = "ginlog.timings"
+
+// handlerKey keeps name of the last handler wrapped with Timed called by a request, see handlerName
+const handlerKey = "ginlog.handler"
+
+// WithTimings logs "timings" array of handlers wrapped with Timed, in chain order, and
+// aborted_by/aborted_at when one of them aborted the chain
+func WithTimings() Option {
+	return optionFunc(func(c *config) {
+		c.timings = true
+	})
+}
+
+// Timed wraps handlers to measure them when the request is logged with WithTimings,
+// otherwise handlers are called as they are:
+//
+//	r.Use(ginlog.New(ginlog.WithTimings()))
+//	r.Use(ginlog.Timed(auth, rateLimit)...)
+//	r.POST("/payments", ginlog.Timed(validate, createPayment)...)
+//
+// A middleware is measured without handlers it calls with c.Next, its duration including them is logged as total
+func Timed(handlers ...gin.HandlerFunc) gin.HandlersChain {
+	chain := make(gin.HandlersChain, len(handlers))
+	for i, h := range handlers {
+		chain[i] = timed(h, nameOf(h))
+	}
+
+	return chain
+}
+
+func timed(h gin.HandlerFunc, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(handlerKey, name)
+		t, ok := c.Value(timingsKey).(*chainTimings)
+		if !ok {
+			h(c)
+			return
+		}
+
+		aborted := c.IsAborted()
+		i := t.start(name)
+		h(c)
+		t.end(i, !aborted && c.IsAborted())
+	}
+}
+
+func nameOf(h gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+}
+
+// timedName is the name gin reports for every handler wrapped with Timed
+var timedName = nameOf(timed(nil, ""))
+
+// handlerName returns c.HandlerName, or the name of the wrapped handler if the last handler is wrapped with Timed
+func handlerName(c *gin.Context) string {
+	name := c.HandlerName()
+	if name == timedName {
+		if wrapped, ok := c.Value(handlerKey).(string); ok {
+			return wrapped
+		}
+	}
+
+	return name
+}
+
+type handlerTiming struct {
+	handler string
+	start   time.Time
+	self    time.Duration
+	total   time.Duration
+}
+
+func (ht *handlerTiming) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("handler", ht.handler)
+	enc.AddDuration("duration", ht.self)
+	enc.AddDuration("total", ht.total)
+
+	return nil
+}
+
+// chainTimings collects durations of Timed handlers of one request. Handlers nest through c.Next,
+// so durations of running handlers' children are summed on a stack to get exclusive durations
+type chainTimings struct {
+	mu        sync.Mutex
+	handlers  []*handlerTiming
+	children  []time.Duration
+	abortedBy string
+	abortedAt int
+}
+
+func (t *chainTimings) start(name string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handlers = append(t.handlers, &handlerTiming{handler: name, start: time.Now()})
+	t.children = append(t.children, 0)
+
+	return len(t.handlers) - 1
+}
+
+func (t *chainTimings) end(i int, aborted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ht := t.handlers[i]
+	ht.total = time.Since(ht.start)
+	last := len(t.children) - 1
+	ht.self = ht.total - t.children[last]
+	t.children = t.children[:last]
+	if last > 0 {
+		t.children[last-1] += ht.total
+	}
+	// the innermost aborting handler returns first
+	if aborted && t.abortedBy == "" {
+		t.abortedBy, t.abortedAt = ht.handler, i
+	}
+}
+
+func (t *chainTimings) fields() []zap.Field {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fields := []zap.Field{zap.Array("timings", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, ht := range t.handlers {
+			if err := enc.AppendObject(ht); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))}
+	if t.abortedBy != "" {
+		fields = append(fields, zap.String("aborted_by", t.abortedBy), zap.Int("aborted_at", t.abortedAt))
+	}
+
+	return fields
+}
@@ -0,0 +1,106 @@
+package ginlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func slowAuth(c *gin.Context) {
+	time.Sleep(10 * time.Millisecond)
+	if c.GetHeader("Authorization") == "" {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	c.Next()
+}
+
+func validate(c *gin.Context) {}
+
+func handle(c *gin.Context) {
+	time.Sleep(20 * time.Millisecond)
+	c.Status(http.StatusOK)
+}
+
+func TestTimings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithTimings()))
+	r.Use(Timed(slowAuth)...)
+	r.GET("/", Timed(validate, handle)...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	serve(r, req)
+	serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+
+	fields := entries[0].ContextMap()
+	timings, ok := fields["timings"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, timings, 3)
+	names := make([]interface{}, len(timings))
+	for i := range timings {
+		names[i] = timings[i].(map[string]interface{})["handler"]
+	}
+	assert.Equal(t, []interface{}{
+		"libs/logger/instrumentation/ginlog.slowAuth",
+		"libs/logger/instrumentation/ginlog.validate",
+		"libs/logger/instrumentation/ginlog.handle",
+	}, names, "chain order")
+	auth := timings[0].(map[string]interface{})
+	self, total := auth["duration"].(time.Duration), auth["total"].(time.Duration)
+	assert.GreaterOrEqual(t, self, 10*time.Millisecond)
+	assert.LessOrEqual(t, self, total-20*time.Millisecond, "handlers called with c.Next are excluded")
+	assert.GreaterOrEqual(t, total, 30*time.Millisecond)
+	assert.NotContains(t, fields, "aborted_by")
+
+	fields = entries[1].ContextMap()
+	assert.Len(t, fields["timings"], 1)
+	assert.Equal(t, "libs/logger/instrumentation/ginlog.slowAuth", fields["aborted_by"])
+	assert.Equal(t, int64(0), fields["aborted_at"])
+}
+
+func TestTimedWithoutTimings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New())
+	r.GET("/", Timed(validate, handle)...)
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, logs.All()[0].ContextMap(), "timings")
+}
+
+func TestTimedHandlerName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := observe(t)
+
+	r := gin.New()
+	r.Use(New(WithTimings(), WithExtra(ExtraHandler)))
+	r.Use(Timed(slowAuth)...)
+	r.GET("/timed", Timed(validate, handle)...)
+	r.GET("/plain", handle)
+	req := httptest.NewRequest(http.MethodGet, "/timed", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	serve(r, req)
+	req = httptest.NewRequest(http.MethodGet, "/plain", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	serve(r, req)
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "libs/logger/instrumentation/ginlog.handle", entries[0].ContextMap()["handler"], "wrapped handler")
+	assert.Equal(t, "libs/logger/instrumentation/ginlog.handle", entries[1].ContextMap()["handler"], "after Timed middleware")
+}
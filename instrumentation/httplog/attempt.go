@@ -0,0 +1,112 @@
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"libs/logger"
+)
+
+// hop is one request actually sent over the wire for a logical outgoing call
+type hop struct {
+	requestID string
+	parentID  string
+	attempt   int
+	redirect  bool
+}
+
+func (h *hop) fields() []zap.Field {
+	fields := []zap.Field{logger.OutgoingRequestID(h.requestID), logger.Attempt(h.attempt)}
+	if h.parentID != "" {
+		fields = append(fields, logger.ParentRequestID(h.parentID))
+	}
+	if h.redirect {
+		fields = append(fields, zap.Bool("redirect", true))
+	}
+
+	return fields
+}
+
+// attempts counts hops made with one context, see TrackAttempts
+type attempts struct {
+	mu        sync.Mutex
+	count     int
+	requestID string
+}
+
+type attemptsCtxKey struct{}
+
+// TrackAttempts returns a context which links every outgoing request sent with it to the previous one.
+// Bind it once per logical call before handing the context to a retrying client:
+// without it only redirects followed by http.Client are linked.
+func TrackAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptsCtxKey{}, &attempts{})
+}
+
+// Attempt returns number of requests sent with context returned by TrackAttempts, redirects included
+func Attempt(ctx context.Context) int {
+	a, ok := ctx.Value(attemptsCtxKey{}).(*attempts)
+	if !ok {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.count
+}
+
+type hopCtxKey struct{}
+
+// nextHop generates request id for req and links it to the previous hop:
+// redirect response it follows or TrackAttempts counter. req is not modified
+func nextHop(req *http.Request) hop {
+	h := hop{requestID: uuid.NewString(), attempt: 1}
+
+	if req.Response != nil {
+		h.redirect = true
+		if prev, ok := sentHop(req.Response.Request); ok {
+			h.parentID = prev.requestID
+			h.attempt = prev.attempt + 1
+		}
+	}
+
+	if a, ok := req.Context().Value(attemptsCtxKey{}).(*attempts); ok {
+		a.mu.Lock()
+		a.count++
+		h.attempt = a.count
+		if a.requestID != "" {
+			h.parentID = a.requestID
+		}
+		a.requestID = h.requestID
+		a.mu.Unlock()
+	}
+
+	return h
+}
+
+// sentHop returns hop req was sent as, req is a request made by outgoing
+func sentHop(req *http.Request) (hop, bool) {
+	if req == nil {
+		return hop{}, false
+	}
+	h, ok := req.Context().Value(hopCtxKey{}).(hop)
+
+	return h, ok
+}
+
+// outgoing returns copy of req to be sent as hop h. Headers are cloned, so the caller's request is never
+// modified. Context of the copy carries h: the response refers to the copy, so a redirect http.Client
+// follows is linked to h
+func outgoing(req *http.Request, h hop) *http.Request {
+	out := req.WithContext(context.WithValue(req.Context(), hopCtxKey{}, h))
+	out.Header = req.Header.Clone()
+	if out.Header == nil {
+		out.Header = make(http.Header)
+	}
+
+	return out
+}
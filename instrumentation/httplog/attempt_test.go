@@ -0,0 +1,143 @@
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"libs/logger"
+)
+
+func observe(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger.New("debug", "test", zap.WrapCore(func(zapcore.Core) zapcore.Core { return core }))
+	t.Cleanup(func() {
+		logger.New("debug", "test", zap.WrapCore(func(zapcore.Core) zapcore.Core { return zapcore.NewNopCore() }))
+	})
+
+	return logs
+}
+
+func TestNextHop(t *testing.T) {
+	first := get("http://api.local/a")
+	h1 := nextHop(first)
+	assert.Equal(t, hop{requestID: h1.requestID, attempt: 1}, h1)
+
+	// http.Client sets Response of the redirected request, the transport returns the request it sent
+	redirected := get("http://api.local/b")
+	redirected.Response = &http.Response{StatusCode: http.StatusFound, Request: outgoing(first, h1)}
+	h2 := nextHop(redirected)
+	assert.Equal(t, hop{requestID: h2.requestID, parentID: h1.requestID, attempt: 2, redirect: true}, h2)
+
+	// retries are linked by TrackAttempts only
+	h3 := nextHop(first)
+	assert.Equal(t, hop{requestID: h3.requestID, attempt: 1}, h3)
+	assert.Empty(t, first.Header, "caller's request is not modified")
+}
+
+func TestRetryAttempts(t *testing.T) {
+	logs := observe(t)
+	var sent []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Header.Clone())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	transport := New(http.DefaultTransport)
+	req, _ := http.NewRequestWithContext(TrackAttempts(context.Background()), http.MethodGet, srv.URL, nil)
+	// copied from the inbound request, it is not a previous hop
+	req.Header.Set(logger.HTTPHeaderRequestID, "inbound")
+	for i := 0; i < 4; i++ {
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.Header{"X-Log-Request-Id": {"inbound"}}, req.Header, "caller's request is not modified")
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 4)
+	var prev string
+	for i, e := range entries {
+		fields := e.ContextMap()
+		assert.EqualValues(t, i+1, fields["attempt"])
+		if prev == "" {
+			assert.NotContains(t, fields, "parent_request_id")
+		} else {
+			assert.Equal(t, prev, fields["parent_request_id"])
+		}
+		prev = fields["outgoing_request_id"].(string)
+		assert.Equal(t, prev, sent[i].Get(logger.HTTPHeaderRequestID))
+	}
+}
+
+func TestRedirectAttempts(t *testing.T) {
+	logs := observe(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a" {
+			http.Redirect(w, r, "/b", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: New(http.DefaultTransport, NoContext("*", "*"))}
+	resp, err := client.Get(srv.URL + "/a")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	first, second := entries[0].ContextMap(), entries[1].ContextMap()
+	assert.EqualValues(t, 1, first["attempt"])
+	assert.EqualValues(t, 2, second["attempt"])
+	assert.Equal(t, first["outgoing_request_id"], second["parent_request_id"])
+	assert.Equal(t, true, second["redirect"])
+}
+
+// TestConcurrentReuse sends one request from several goroutines, run with -race
+func TestConcurrentReuse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	transport := New(http.DefaultTransport, SkipLog(http.MethodGet, "/skipped"))
+	for _, path := range []string{"/logged", "/skipped"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := transport.RoundTrip(req)
+				if assert.NoError(t, err) {
+					_ = resp.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Empty(t, req.Header, path)
+	}
+}
+
+func TestTrackAttempts(t *testing.T) {
+	ctx := TrackAttempts(context.Background())
+	assert.Equal(t, 0, Attempt(ctx))
+
+	var prev string
+	for i := 1; i <= 3; i++ {
+		// fresh request each time, e.g. retries without passing logging context
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.local/a", nil)
+		h := nextHop(req)
+		assert.Equal(t, i, h.attempt)
+		assert.Equal(t, prev, h.parentID)
+		prev = h.requestID
+	}
+	assert.Equal(t, 3, Attempt(ctx))
+}
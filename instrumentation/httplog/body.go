@@ -0,0 +1,204 @@
+package httplog
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxDecodedBody limits decompressed size of a logged body, protecting from compression bombs
+const maxDecodedBody = 8 << 20
+
+// SkipDecoding logs bodies of endpoints as they are sent over the wire.
+// By default bodies with Content-Encoding gzip/deflate/br are decompressed and
+// text in Content-Type charset is converted to UTF-8, for logging only
+func SkipDecoding(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.Decode = false
+	}}
+}
+
+// WithPretty indents JSON and XML bodies of every request, see EndpointPretty
+func WithPretty() Option {
+	return &patchOption{patch: enablePretty}
+}
+
+// EndpointPretty indents logged JSON and XML bodies of endpoints. Type is taken from Content-Type,
+// bodies which fail to parse are logged as is
+func EndpointPretty(method string, paths ...string) Option {
+	return &patchOption{patch: enablePretty, blackList: buildBlackList(method, paths)}
+}
+
+func enablePretty(s *settings) {
+	s.Pretty = true
+}
+
+// drainBody reads all of b and returns its content with a reader of the same content to replace b,
+// as httputil dump functions do
+func drainBody(b io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if b == nil || b == http.NoBody {
+		return nil, b, nil
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(b); err != nil {
+		return nil, b, err
+	}
+	if err := b.Close(); err != nil {
+		return nil, b, err
+	}
+
+	return buf.Bytes(), io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+}
+
+// formatBody prepares body for logging according to settings, never failing:
+// a step that can't be done leaves body as it was. Sensitive fields are masked once body is decoded
+func formatBody(header http.Header, body []byte, s settings) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if s.Decode {
+		body = decompress(header.Get("Content-Encoding"), body)
+		body = toUTF8(params["charset"], body)
+	}
+	body = s.Redact.Body(header.Get("Content-Type"), body)
+	if s.Pretty {
+		body = indent(mediaType, body)
+	}
+
+	return body
+}
+
+// decompress undoes Content-Encoding codings, applied in listed order
+func decompress(encoding string, body []byte) []byte {
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			// deflate is zlib wrapped by spec, but raw deflate is common as well
+			r, err = zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				r, err = flate.NewReader(bytes.NewReader(body)), nil
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			// identity or unknown coding: nothing to undo
+			continue
+		}
+		if err != nil {
+			return body
+		}
+
+		// truncated bodies (e.g. streamed prefix) are decoded as far as possible
+		decoded, err := io.ReadAll(io.LimitReader(r, maxDecodedBody))
+		if err != nil && len(decoded) == 0 {
+			return body
+		}
+		body = decoded
+	}
+
+	return body
+}
+
+func toUTF8(charset string, body []byte) []byte {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return body
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return body
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+
+	return decoded
+}
+
+func indent(mediaType string, body []byte) []byte {
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, body, "", "  "); err != nil {
+			return body
+		}
+		return buf.Bytes()
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return indentXML(body)
+	default:
+		return body
+	}
+}
+
+// indentXML re-encodes tokens with indentation, whitespace between elements is dropped.
+// Raw tokens with prefixed names are used, so namespaces are written exactly as received
+func indentXML(body []byte) []byte {
+	var buf bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	// body is already UTF-8 at this point, declared encoding is kept as is
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return body
+		}
+		if data, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := enc.EncodeToken(prefixedNames(xml.CopyToken(tok))); err != nil {
+			return body
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return body
+	}
+
+	return buf.Bytes()
+}
+
+func prefixedNames(tok xml.Token) xml.Token {
+	name := func(n xml.Name) xml.Name {
+		if n.Space != "" {
+			return xml.Name{Local: n.Space + ":" + n.Local}
+		}
+		return n
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name = name(t.Name)
+		for i := range t.Attr {
+			t.Attr[i].Name = name(t.Attr[i].Name)
+		}
+		return t
+	case xml.EndElement:
+		t.Name = name(t.Name)
+		return t
+	default:
+		return tok
+	}
+}
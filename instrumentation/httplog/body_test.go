@@ -0,0 +1,76 @@
+package httplog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBody(t *testing.T) {
+	gz := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	deflate := func(s string) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	br := func(s string) []byte {
+		var buf bytes.Buffer
+		w := brotli.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	decode := settings{Decode: true}
+	pretty := settings{Decode: true, Pretty: true}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     []byte
+		settings settings
+		want     string
+	}{
+		{"plain", http.Header{}, []byte("hello"), decode, "hello"},
+		{"gzip", http.Header{"Content-Encoding": {"gzip"}}, gz("hello"), decode, "hello"},
+		{"deflate", http.Header{"Content-Encoding": {"deflate"}}, deflate("hello"), decode, "hello"},
+		{"br", http.Header{"Content-Encoding": {"br"}}, br("hello"), decode, "hello"},
+		{"stacked codings", http.Header{"Content-Encoding": {"deflate, gzip"}}, gz(string(deflate("hello"))), decode, "hello"},
+		{"decoding disabled", http.Header{"Content-Encoding": {"gzip"}}, gz("hello"), settings{}, string(gz("hello"))},
+		{"broken gzip", http.Header{"Content-Encoding": {"gzip"}}, []byte("garbage"), decode, "garbage"},
+		{"truncated gzip", http.Header{"Content-Encoding": {"gzip"}}, gz("hello world")[:20], decode, "hello"},
+		{"charset", http.Header{"Content-Type": {"text/plain; charset=windows-1251"}}, []byte{0xcf, 0xf0, 0xe8, 0xe2, 0xe5, 0xf2}, decode, "Привет"},
+		{"unknown charset", http.Header{"Content-Type": {"text/plain; charset=x-unknown"}}, []byte("hi"), decode, "hi"},
+		{"json", http.Header{"Content-Type": {"application/json"}}, []byte(`{"a":[1,2]}`), pretty, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{"problem+json", http.Header{"Content-Type": {"application/problem+json; charset=utf-8"}}, []byte(`{"a":1}`), pretty, "{\n  \"a\": 1\n}"},
+		{"json not pretty", http.Header{"Content-Type": {"application/json"}}, []byte(`{"a":1}`), decode, `{"a":1}`},
+		{"invalid json", http.Header{"Content-Type": {"application/json"}}, []byte(`{"a":`), pretty, `{"a":`},
+		{"gzipped json", http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}, gz(`{"a":1}`), pretty, "{\n  \"a\": 1\n}"},
+		{
+			"xml",
+			http.Header{"Content-Type": {"text/xml"}},
+			[]byte(`<s:Envelope xmlns:s="urn:s"><s:Body a="1"> <v>x</v></s:Body></s:Envelope>`),
+			pretty,
+			"<s:Envelope xmlns:s=\"urn:s\">\n  <s:Body a=\"1\">\n    <v>x</v>\n  </s:Body>\n</s:Envelope>",
+		},
+		{"invalid xml", http.Header{"Content-Type": {"application/xml"}}, []byte(`<a><b></a>`), pretty, `<a><b></a>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(formatBody(tt.header, tt.body, tt.settings)))
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package httplog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"libs/logger"
+)
+
+// Match selects request parts compared when a recorded interaction is looked up for replay
+type Match int
+
+const (
+	MatchMethod Match = 1 << iota
+	MatchHost
+	MatchPath
+	MatchQuery
+	MatchBody // sha256 of request body
+
+	// DefaultMatch compares everything except body
+	DefaultMatch = MatchMethod | MatchHost | MatchPath | MatchQuery
+)
+
+// Cassette is http.RoundTripper recording real interactions into a fixture file or replaying them
+// without network, for deterministic integration tests:
+//
+//	path := filepath.Join("testdata", t.Name()+".json")
+//	var rt http.RoundTripper
+//	if os.Getenv("HTTPLOG_RECORD") != "" {
+//		rt, err = httplog.Record(path, http.DefaultTransport)
+//	} else {
+//		rt, err = httplog.Replay(path, httplog.MatchOn(httplog.DefaultMatch|httplog.MatchBody))
+//	}
+//	client := &http.Client{Transport: httplog.New(rt)}
+//
+// Headers, body fields and URL passwords are masked by the same rules as logs, so fixtures can be committed.
+// On replay each interaction is used once, identical requests get recorded responses in recorded order.
+// A request without unused matching interaction fails with *UnmatchedError
+type Cassette struct {
+	mu     sync.Mutex
+	path   string
+	next   http.RoundTripper
+	match  Match
+	redact *logger.Redactor
+	fix    fixture
+	used   []bool
+}
+
+// CassetteOption configures Cassette
+type CassetteOption func(c *Cassette)
+
+// MatchOn sets request parts compared on replay, DefaultMatch if not set
+func MatchOn(m Match) CassetteOption {
+	return func(c *Cassette) {
+		c.match = m
+	}
+}
+
+// CassetteRedactor replaces header and body field redaction rules of recorded fixtures, logger.DefaultRedactor if not set
+func CassetteRedactor(r *logger.Redactor) CassetteOption {
+	return func(c *Cassette) {
+		c.redact = r
+	}
+}
+
+// Record creates Cassette sending requests to next and writing every interaction to path.
+// Existing fixture is overwritten, the file is rewritten after each interaction.
+// Transport errors are returned as is and not recorded
+func Record(path string, next http.RoundTripper, opts ...CassetteOption) (*Cassette, error) {
+	c := newCassette(path, opts)
+	c.next = next
+	if err := writeJSONFile(path, &c.fix); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Replay creates Cassette answering requests with interactions recorded to path, never touching network
+func Replay(path string, opts ...CassetteOption) (*Cassette, error) {
+	c := newCassette(path, opts)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &c.fix); err != nil {
+		return nil, fmt.Errorf("httplog: fixture %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.fix.Interactions))
+
+	return c, nil
+}
+
+func newCassette(path string, opts []CassetteOption) *Cassette {
+	c := &Cassette{path: path, match: DefaultMatch, redact: logger.DefaultRedactor()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.fix.Interactions = []interaction{}
+
+	return c
+}
+
+// Unused returns recorded interactions not replayed yet as "METHOD URL", so tests may assert
+// every expected call was made
+func (c *Cassette) Unused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unused []string
+	for i, used := range c.used {
+		if !used {
+			r := c.fix.Interactions[i].Request
+			unused = append(unused, r.Method+" "+r.URL)
+		}
+	}
+
+	return unused
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.next == nil {
+		return c.replay(req, body)
+	}
+
+	return c.record(req, body)
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	var respBody []byte
+	if respBody, resp.Body, err = drainBody(resp.Body); err != nil {
+		return nil, err
+	}
+
+	in := interaction{
+		Request: recordedRequest{
+			Method:     req.Method,
+			URL:        req.URL.Redacted(),
+			Header:     c.redact.Header(req.Header),
+			BodySHA256: bodyHash(body),
+		},
+		Response: recordedResponse{
+			Status: resp.StatusCode,
+			Header: c.redact.Header(resp.Header),
+		},
+	}
+	// body hash is taken before redaction, so replay matches requests as they are sent
+	in.Request.Body, in.Request.BodyEncoding = encodeBody(c.redact.Body(req.Header.Get("Content-Type"), body))
+	in.Response.Body, in.Response.BodyEncoding = encodeBody(c.redact.Body(resp.Header.Get("Content-Type"), respBody))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fix.Interactions = append(c.fix.Interactions, in)
+	if err := writeJSONFile(c.path, &c.fix); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.fix.Interactions {
+		in := &c.fix.Interactions[i]
+		if c.used[i] || !c.matches(&in.Request, req, body) {
+			continue
+		}
+		respBody, err := decodeBody(in.Response.Body, in.Response.BodyEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("httplog: fixture %s: %w", c.path, err)
+		}
+		c.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, &UnmatchedError{Fixture: c.path, Method: req.Method, URL: req.URL.Redacted(), BodySHA256: bodyHash(body)}
+}
+
+func (c *Cassette) matches(r *recordedRequest, req *http.Request, body []byte) bool {
+	if c.match&MatchMethod != 0 && r.Method != req.Method {
+		return false
+	}
+	if c.match&MatchBody != 0 && r.BodySHA256 != bodyHash(body) {
+		return false
+	}
+	if c.match&(MatchHost|MatchPath|MatchQuery) == 0 {
+		return true
+	}
+	u, err := req.URL.Parse(r.URL)
+	if err != nil {
+		return false
+	}
+
+	return (c.match&MatchHost == 0 || strings.EqualFold(u.Host, req.URL.Host)) &&
+		(c.match&MatchPath == 0 || u.Path == req.URL.Path) &&
+		(c.match&MatchQuery == 0 || u.Query().Encode() == req.URL.Query().Encode())
+}
+
+// UnmatchedError is returned on replay for a request without unused recorded interaction
+type UnmatchedError struct {
+	Fixture    string
+	Method     string
+	URL        string
+	BodySHA256 string
+}
+
+func (e *UnmatchedError) Error() string {
+	return fmt.Sprintf("httplog: no recorded interaction in %s for %s %s (body sha256 %s)", e.Fixture, e.Method, e.URL, e.BodySHA256)
+}
+
+// fixture is the file format of Cassette
+type fixture struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+	BodySHA256   string      `json:"body_sha256"`
+}
+
+type recordedResponse struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// readRequestBody reads and closes request body, as RoundTripper must close it
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+
+	return io.ReadAll(req.Body)
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeBody keeps body as is: text if it is valid UTF-8, base64 otherwise
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("unknown body encoding %q", encoding)
+	}
+}
+
+// writeJSONFile writes v to a temporary file and renames it, so readers never see a partial document
+func writeJSONFile(path string, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(raw); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,140 @@
+package httplog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"libs/logger"
+)
+
+func TestCassette(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.URL.Path + ":" + string(body) + ":" + string(rune('0'+calls))))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	send := func(rt http.RoundTripper, method, url, body string) (*http.Response, string, error) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := (&http.Client{Transport: New(rt)}).Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		return resp, string(raw), nil
+	}
+
+	rec, err := Record(path, http.DefaultTransport)
+	require.NoError(t, err)
+	for _, body := range []string{"a", "b", "a"} {
+		_, _, err := send(rec, http.MethodPost, srv.URL+"/payments?x=1", body)
+		require.NoError(t, err)
+	}
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret", "headers are redacted")
+
+	t.Run("default match replays in order", func(t *testing.T) {
+		rep, err := Replay(path)
+		require.NoError(t, err)
+		for _, want := range []string{"/payments:a:1", "/payments:b:2", "/payments:a:3"} {
+			resp, body, err := send(rep, http.MethodPost, srv.URL+"/payments?x=1", "whatever")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+			assert.Equal(t, want, body)
+		}
+		assert.Empty(t, rep.Unused())
+
+		_, _, err = send(rep, http.MethodPost, srv.URL+"/payments?x=1", "a")
+		var unmatched *UnmatchedError
+		require.True(t, errors.As(err, &unmatched), "exhausted cassette fails: %v", err)
+		assert.Equal(t, http.MethodPost, unmatched.Method)
+	})
+
+	t.Run("body match", func(t *testing.T) {
+		rep, err := Replay(path, MatchOn(DefaultMatch|MatchBody))
+		require.NoError(t, err)
+		_, body, err := send(rep, http.MethodPost, srv.URL+"/payments?x=1", "b")
+		require.NoError(t, err)
+		assert.Equal(t, "/payments:b:2", body)
+		_, body, err = send(rep, http.MethodPost, srv.URL+"/payments?x=1", "a")
+		require.NoError(t, err)
+		assert.Equal(t, "/payments:a:1", body)
+		assert.Equal(t, []string{http.MethodPost + " " + srv.URL + "/payments?x=1"}, rep.Unused())
+
+		_, _, err = send(rep, http.MethodPost, srv.URL+"/payments?x=1", "c")
+		assert.Error(t, err)
+	})
+
+	t.Run("unmatched request", func(t *testing.T) {
+		rep, err := Replay(path)
+		require.NoError(t, err)
+		for _, url := range []string{srv.URL + "/payments?x=2", srv.URL + "/refunds?x=1", "http://other.local/payments?x=1"} {
+			_, _, err := send(rep, http.MethodPost, url, "a")
+			assert.Error(t, err, url)
+		}
+		_, _, err = send(rep, http.MethodGet, srv.URL+"/payments?x=1", "")
+		assert.Error(t, err)
+
+		rep, err = Replay(path, MatchOn(MatchMethod|MatchPath))
+		require.NoError(t, err)
+		_, _, err = send(rep, http.MethodPost, "http://other.local/payments?x=2", "")
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 3, calls, "replay never touches network")
+}
+
+func TestCassetteRedactsBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"issued","ttl":60}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	redactor := logger.DefaultRedactor().WithFields("password", "token")
+	rec, err := Record(path, http.DefaultTransport, CassetteRedactor(redactor))
+	require.NoError(t, err)
+
+	login := func(rt http.RoundTripper) string {
+		u, _ := url.Parse(srv.URL + "/login")
+		u.User = url.UserPassword("svc", "hunter2")
+		req, _ := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(`{"login":"x","password":"secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		return string(raw)
+	}
+	assert.Equal(t, `{"token":"issued","ttl":60}`, login(rec), "caller gets the response as is")
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"secret", "issued", "hunter2"} {
+		assert.NotContains(t, string(raw), secret)
+	}
+
+	rep, err := Replay(path, MatchOn(DefaultMatch|MatchBody), CassetteRedactor(redactor))
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"[REDACTED]","ttl":60}`, login(rep), "matched by hash of the body as sent")
+}
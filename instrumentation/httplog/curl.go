@@ -0,0 +1,153 @@
+package httplog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+// curl modes
+const (
+	curlNever = iota
+	curlOnFailure
+	curlAlways
+)
+
+// WithCurlOnFailure adds ready-to-run "curl" command to entries of failed calls:
+// ones classified at warn level or above (transport errors and 5xx by default)
+func WithCurlOnFailure() Option {
+	return &patchOption{patch: setCurl(curlOnFailure)}
+}
+
+// EndpointCurl adds "curl" command to every entry of endpoints
+func EndpointCurl(method string, paths ...string) Option {
+	return &patchOption{patch: setCurl(curlAlways), blackList: buildBlackList(method, paths)}
+}
+
+func setCurl(mode int) patch {
+	return func(s *settings) {
+		s.Curl = mode
+	}
+}
+
+type curlCtxKey struct{}
+
+// BindCurl returns a context, requests made with which are logged with "curl" command on demand
+func BindCurl(ctx context.Context) context.Context {
+	return context.WithValue(ctx, curlCtxKey{}, true)
+}
+
+// wantCurl reports whether entry needs curl command. Hidden requests never get one
+func (e *entry) wantCurl(level zapcore.Level) bool {
+	if !e.setting.Request {
+		return false
+	}
+	if on, _ := e.req.Context().Value(curlCtxKey{}).(bool); on {
+		return true
+	}
+	switch e.setting.Curl {
+	case curlAlways:
+		return true
+	case curlOnFailure:
+		return level >= zapcore.WarnLevel
+	default:
+		return false
+	}
+}
+
+// curlCommand builds POSIX shell command replaying req. Sensitive headers and body fields are masked
+// with redactor, body is omitted when nil. Content-Length is left for curl to compute
+func curlCommand(req *http.Request, body []byte, redactor *logger.Redactor) string {
+	body = redactor.Body(req.Header.Get("Content-Type"), body)
+
+	var b strings.Builder
+	if len(body) > 0 && !printable(body) {
+		// binary bodies can't be passed as an argument, NUL in particular
+		b.WriteString("printf ")
+		b.WriteString(printfFormat(body))
+		b.WriteString(" | ")
+	}
+
+	b.WriteString("curl -X ")
+	b.WriteString(shellQuote(req.Method))
+
+	header := redactor.Header(req.Header)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if name != "Content-Length" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if req.Host != "" && req.Host != req.URL.Host {
+		b.WriteString(" -H ")
+		b.WriteString(shellQuote("Host: " + req.Host))
+	}
+	for _, name := range names {
+		for _, v := range header[name] {
+			b.WriteString(" -H ")
+			b.WriteString(shellQuote(name + ": " + v))
+		}
+	}
+
+	switch {
+	case len(body) == 0:
+	case printable(body):
+		b.WriteString(" --data-binary ")
+		b.WriteString(shellQuote(string(body)))
+	default:
+		b.WriteString(" --data-binary @-")
+	}
+
+	b.WriteString(" ")
+	b.WriteString(shellQuote(req.URL.Redacted()))
+
+	return b.String()
+}
+
+// shellQuote wraps s in single quotes, nothing is special inside them except the quote itself
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printable reports whether body can be passed as a shell argument as is
+func printable(body []byte) bool {
+	if !utf8.Valid(body) {
+		return false
+	}
+	for _, c := range body {
+		if c == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// printfFormat encodes arbitrary bytes as quoted printf format printing them back
+func printfFormat(body []byte) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, c := range body {
+		switch {
+		case c == '%':
+			b.WriteString("%%")
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\'' || c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+
+	return b.String()
+}
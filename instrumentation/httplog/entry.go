@@ -0,0 +1,86 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// entry is a log record of one hop. It is written once the response is handled:
+// right after RoundTrip or, for streamed bodies, when the caller closes response body
+type entry struct {
+	l        *zap.Logger
+	setting  settings
+	req      *http.Request
+	resp     *http.Response
+	err      error
+	fields   []zap.Field
+	errs     []error
+	reqBody  []byte
+	respBody []byte
+	start    time.Time
+	end      time.Time
+	tm       *timings
+}
+
+func (e *entry) write() {
+	if e.end.IsZero() {
+		e.end = time.Now()
+	}
+	level := e.setting.level(e.req, e.resp, e.err)
+	fields := e.fields
+	if e.wantCurl(level) {
+		fields = append(fields, zap.String("curl", curlCommand(e.req, e.reqBody, e.setting.Redact)))
+	}
+	if e.setting.HAR != nil {
+		if err := e.setting.HAR.Record(e.harEntry()); err != nil {
+			e.errs = append(e.errs, err)
+		}
+	}
+	fields = append(fields, zap.Errors("errors", e.errs))
+	if ce := e.l.Check(level, "http request sent"); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// dumpRequest dumps request head and, if enabled, its body formatted for logging.
+// Body is read into memory and replaced with a copy, as httputil.DumpRequestOut does
+func (e *entry) dumpRequest() ([]byte, error) {
+	if e.setting.RequestBody {
+		var err error
+		if e.reqBody, e.req.Body, err = drainBody(e.req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	redacted := *e.req
+	redacted.Header = e.setting.Redact.Header(e.req.Header)
+	dump, err := httputil.DumpRequestOut(&redacted, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dump, formatBody(e.req.Header, e.reqBody, e.setting)...), nil
+}
+
+// dumpResponse dumps response head and, if enabled, its body formatted for logging.
+// Body is read into memory and replaced with a copy, as httputil.DumpResponse does
+func (e *entry) dumpResponse() ([]byte, error) {
+	if e.setting.ResponseBody {
+		var err error
+		if e.respBody, e.resp.Body, err = drainBody(e.resp.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	redacted := *e.resp
+	redacted.Header = e.setting.Redact.Header(e.resp.Header)
+	dump, err := httputil.DumpResponse(&redacted, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dump, formatBody(e.resp.Header, e.respBody, e.setting)...), nil
+}
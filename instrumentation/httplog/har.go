@@ -0,0 +1,444 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is HTTP Archive 1.2 document, http://www.softwareishard.com/blog/har-12-spec/
+// Only fields httplog can fill are declared
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root object of HAR document
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application which recorded HAR
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one recorded request with its response
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest describes the sent request, headers and body fields are redacted
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes the received response, headers and body fields are redacted
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the response body. Binary content is base64 encoded
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are phase durations in milliseconds, -1 for phases that didn't happen or weren't traced.
+// Connect includes SSL as spec requires
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder stores recorded entries. Record is called after every logged call,
+// its error is logged with the entry
+type HARRecorder interface {
+	Record(entry HAREntry) error
+}
+
+// WithHAR records every logged call to rec: request and response headers and body fields masked by redaction rules
+// and bodies as they are logged. Combine with WithTrace to get phase timings.
+// Used inside Host records that host only
+func WithHAR(rec HARRecorder) Option {
+	return &patchOption{patch: func(s *settings) {
+		s.HAR = rec
+	}}
+}
+
+// harEntry converts entry to HAR
+func (e *entry) harEntry() HAREntry {
+	he := HAREntry{
+		StartedDateTime: e.start,
+		Time:            ms(e.end.Sub(e.start)),
+		Request: HARRequest{
+			Method:      e.req.Method,
+			URL:         e.req.URL.Redacted(),
+			HTTPVersion: e.req.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(e.setting.Redact.Header(e.req.Header)),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(e.reqBody),
+		},
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: ms(e.end.Sub(e.start))},
+	}
+	if he.Request.HTTPVersion == "" {
+		he.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range e.req.URL.Query() {
+		for _, v := range values {
+			he.Request.QueryString = append(he.Request.QueryString, HARNameValue{Name: name, Value: v})
+		}
+	}
+	if len(e.reqBody) > 0 {
+		he.Request.PostData = &HARPostData{
+			MimeType: e.req.Header.Get("Content-Type"),
+			Text:     string(formatBody(e.req.Header, e.reqBody, e.setting)),
+		}
+	}
+
+	if e.resp != nil {
+		he.Response.Status = e.resp.StatusCode
+		he.Response.StatusText = strings.TrimSpace(strings.TrimPrefix(e.resp.Status, fmt.Sprint(e.resp.StatusCode)))
+		he.Response.HTTPVersion = e.resp.Proto
+		he.Response.Headers = harHeaders(e.setting.Redact.Header(e.resp.Header))
+		he.Response.RedirectURL = e.resp.Header.Get("Location")
+		he.Response.BodySize = len(e.respBody)
+
+		content := formatBody(e.resp.Header, e.respBody, e.setting)
+		he.Response.Content = HARContent{Size: len(content), MimeType: e.resp.Header.Get("Content-Type")}
+		if mediaType, _, _ := mime.ParseMediaType(he.Response.Content.MimeType); utf8.Valid(content) || strings.HasPrefix(mediaType, "text/") {
+			he.Response.Content.Text = string(content)
+		} else {
+			he.Response.Content.Text = base64.StdEncoding.EncodeToString(content)
+			he.Response.Content.Encoding = "base64"
+		}
+	} else if e.err != nil {
+		he.Comment = e.err.Error()
+	}
+
+	if e.tm != nil {
+		e.tm.harTimings(&he, e.end)
+	}
+
+	return he
+}
+
+func harHeaders(h http.Header) []HARNameValue {
+	headers := make([]HARNameValue, 0, len(h))
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, HARNameValue{Name: name, Value: v})
+		}
+	}
+
+	return headers
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harTimings fills traced phases of he
+func (tm *timings) harTimings(he *HAREntry, end time.Time) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	phase := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return ms(to.Sub(from))
+	}
+	t := &he.Timings
+	t.Blocked = phase(tm.getConn, tm.gotConn)
+	t.DNS = phase(tm.dnsStart, tm.dnsDone)
+	t.Connect = phase(tm.connectStart, tm.tlsDone)
+	if t.Connect < 0 {
+		t.Connect = phase(tm.connectStart, tm.connectDone)
+	}
+	t.SSL = phase(tm.tlsStart, tm.tlsDone)
+	// blocked must not include dns and connect
+	for _, p := range []float64{t.DNS, t.Connect} {
+		if t.Blocked > 0 && p > 0 {
+			t.Blocked -= p
+		}
+	}
+	if send := phase(tm.gotConn, tm.wroteRequest); send >= 0 {
+		t.Send = send
+	}
+	if wait := phase(tm.wroteRequest, tm.firstByte); wait >= 0 {
+		t.Wait = wait
+		t.Receive = ms(end.Sub(tm.firstByte))
+	}
+	if tm.remoteAddr != "" {
+		he.ServerIPAddress = tm.remoteAddr
+		if i := strings.LastIndexByte(tm.remoteAddr, ':'); i > 0 {
+			he.ServerIPAddress = strings.Trim(tm.remoteAddr[:i], "[]")
+		}
+	}
+}
+
+func newHAR(entries []HAREntry) *HAR {
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "libs/logger/httplog", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// MemoryRecorder keeps the latest entries in memory. It is an http.Handler serving them as HAR file,
+// so testers can download recorded traffic and open it in browser devtools:
+//
+//	rec := httplog.NewMemoryRecorder(500)
+//	client := &http.Client{Transport: httplog.New(http.DefaultTransport, httplog.WithHAR(rec))}
+//	r.GET("/debug/har", gin.WrapH(rec))
+type MemoryRecorder struct {
+	mu      sync.Mutex
+	limit   int
+	entries []HAREntry
+}
+
+// NewMemoryRecorder creates recorder keeping up to limit latest entries, limit <= 0 means no limit
+func NewMemoryRecorder(limit int) *MemoryRecorder {
+	return &MemoryRecorder{limit: limit}
+}
+
+// Record implements HARRecorder
+func (r *MemoryRecorder) Record(entry HAREntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if r.limit > 0 && len(r.entries) > r.limit {
+		r.entries = append(r.entries[:0:0], r.entries[len(r.entries)-r.limit:]...)
+	}
+
+	return nil
+}
+
+// HAR returns recorded entries as HAR document
+func (r *MemoryRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return newHAR(append([]HAREntry(nil), r.entries...))
+}
+
+// Reset drops recorded entries
+func (r *MemoryRecorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// ServeHTTP downloads recorded entries as HAR file on GET and drops them on DELETE
+func (r *MemoryRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="httplog.har"`)
+		_ = json.NewEncoder(w).Encode(r.HAR())
+	case http.MethodDelete:
+		r.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// FileRecorder appends entries to HAR file. Only the closing brackets are rewritten after the previous
+// entry, so the file is a complete document after every Record and entries are not kept in memory.
+// When it holds maxEntries entries it is renamed with a timestamp suffix
+// (calls.har -> calls-20060102T150405.000.har) and a new file is started
+type FileRecorder struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	count      int   // entries in the current file
+	end        int64 // offset of the closing brackets in the current file
+}
+
+// NewFileRecorder creates recorder writing to path, maxEntries <= 0 disables rotation.
+// Existing file is rotated right away
+func NewFileRecorder(path string, maxEntries int) (*FileRecorder, error) {
+	r := &FileRecorder{path: path, maxEntries: maxEntries}
+	if _, err := os.Stat(path); err == nil {
+		if err := r.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// harHead and harTail surround entries of HAR document written by FileRecorder
+var harHead, harTail = func() ([]byte, []byte) {
+	raw, _ := json.Marshal(newHAR(nil))
+	i := bytes.LastIndex(raw, []byte("[]")) + 1
+
+	return raw[:i], append(raw[i:len(raw):len(raw)], '\n')
+}()
+
+// Record implements HARRecorder
+func (r *FileRecorder) Record(entry HAREntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxEntries > 0 && r.count >= r.maxEntries {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	f, err := r.open()
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if r.count == 0 {
+		sep = "\n"
+	}
+	buf := make([]byte, 0, len(sep)+len(raw)+1+len(harTail))
+	buf = append(buf, sep...)
+	buf = append(buf, raw...)
+	buf = append(buf, '\n')
+	buf = append(buf, harTail...)
+	if _, err := f.WriteAt(buf, r.end); err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.count++
+	r.end += int64(len(buf) - len(harTail))
+
+	return f.Close()
+}
+
+// open opens the current file, starting a new document if there is none
+func (r *FileRecorder) open() (*os.File, error) {
+	const create = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	flag := os.O_WRONLY
+	if r.end == 0 {
+		flag = create
+	}
+	f, err := os.OpenFile(r.path, flag, 0o644)
+	if os.IsNotExist(err) {
+		// removed by somebody, start over
+		r.end = 0
+		f, err = os.OpenFile(r.path, create, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.end == 0 {
+		if _, err := f.Write(harHead); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		r.count, r.end = 0, int64(len(harHead))
+	}
+
+	return f, nil
+}
+
+// rotate renames the current file, a file already moved away (e.g. by logrotate) counts as rotated.
+// Names are claimed with O_EXCL and numbered within a millisecond, so earlier archives are never overwritten
+func (r *FileRecorder) rotate() error {
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext) + "-" + time.Now().Format("20060102T150405.000")
+	for i := 0; ; i++ {
+		rotated := base + ext
+		if i > 0 {
+			rotated = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		f, err := os.OpenFile(rotated, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_ = f.Close()
+
+		if err := os.Rename(r.path, rotated); err != nil {
+			_ = os.Remove(rotated)
+			if !os.IsNotExist(err) {
+				return err
+			}
+		}
+		r.count, r.end = 0, 0
+
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package httplog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	rec := NewMemoryRecorder(2)
+	client := &http.Client{Transport: New(http.DefaultTransport, WithHAR(rec), WithTrace())}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/clients?page=1", strings.NewReader(`{"name":"x"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	har := rec.HAR()
+	assert.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 2, "only the latest entries are kept")
+
+	e := har.Log.Entries[1]
+	assert.Equal(t, http.MethodPost, e.Request.Method)
+	assert.Equal(t, srv.URL+"/clients?page=1", e.Request.URL)
+	assert.Contains(t, e.Request.Headers, HARNameValue{Name: "Authorization", Value: "[REDACTED]"})
+	assert.Equal(t, []HARNameValue{{Name: "page", Value: "1"}}, e.Request.QueryString)
+	assert.Equal(t, `{"name":"x"}`, e.Request.PostData.Text)
+	assert.Equal(t, http.StatusCreated, e.Response.Status)
+	assert.Equal(t, "Created", e.Response.StatusText)
+	assert.Contains(t, e.Response.Headers, HARNameValue{Name: "Set-Cookie", Value: "[REDACTED]"})
+	assert.Equal(t, HARContent{Size: 8, MimeType: "application/json", Text: `{"id":1}`}, e.Response.Content)
+	assert.Equal(t, "127.0.0.1", e.ServerIPAddress)
+	assert.GreaterOrEqual(t, e.Timings.Wait, 0.0)
+	assert.GreaterOrEqual(t, e.Timings.Send, 0.0)
+
+	w := httptest.NewRecorder()
+	rec.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/har", nil))
+	var served HAR
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Len(t, served.Log.Entries, 2)
+
+	rec.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/debug/har", nil))
+	assert.Empty(t, rec.HAR().Log.Entries)
+}
+
+func TestHARRedactsBodies(t *testing.T) {
+	logs := observe(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		_, _ = w.Write([]byte("access_token=secret&expires_in=60"))
+	}))
+	defer srv.Close()
+
+	rec := NewMemoryRecorder(0)
+	client := &http.Client{Transport: New(http.DefaultTransport, WithHAR(rec), RedactFields("password", "access_token"))}
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("svc", "hunter2")
+	req, _ := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(`{"login":"x","password":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	e := rec.HAR().Log.Entries[0]
+	assert.Equal(t, `{"login":"x","password":"[REDACTED]"}`, e.Request.PostData.Text)
+	assert.Equal(t, "http://svc:xxxxx@"+u.Host, e.Request.URL, "URL password")
+	assert.Equal(t, "access_token=[REDACTED]&expires_in=60", e.Response.Content.Text)
+
+	fields := logs.All()[0].ContextMap()
+	assert.Contains(t, fields["request_dump"], `"password":"[REDACTED]"`)
+	assert.NotContains(t, fields["request_dump"], "secret")
+	assert.NotContains(t, fields["response_dump"], "secret")
+}
+
+func TestFileRecorder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calls.har")
+	require.NoError(t, os.WriteFile(path, []byte("previous run"), 0o600))
+
+	rec, err := NewFileRecorder(path, 2)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rec.Record(HAREntry{StartedDateTime: time.Now(), Comment: string(rune('a' + i))}))
+	}
+
+	var current HAR
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &current))
+	require.Len(t, current.Log.Entries, 1)
+	assert.Equal(t, "c", current.Log.Entries[0].Comment)
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "calls-*.har"))
+	require.NoError(t, err)
+	assert.Len(t, rotated, 2, "previous run and the first full file")
+}
+
+func TestFileRecorderAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.har")
+	rec, err := NewFileRecorder(path, 0)
+	require.NoError(t, err)
+
+	read := func() HAR {
+		var har HAR
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(raw, &har), "complete document after every entry")
+		return har
+	}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, rec.Record(HAREntry{Comment: strconv.Itoa(i)}))
+		har := read()
+		require.Len(t, har.Log.Entries, i+1)
+		assert.Equal(t, strconv.Itoa(i), har.Log.Entries[i].Comment)
+	}
+	assert.Equal(t, "1.2", read().Log.Version)
+
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, rec.Record(HAREntry{Comment: "after remove"}))
+	har := read()
+	require.Len(t, har.Log.Entries, 1)
+	assert.Equal(t, "after remove", har.Log.Entries[0].Comment)
+}
+
+func TestFileRecorderRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calls.har")
+	rec, err := NewFileRecorder(path, 1)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, rec.Record(HAREntry{Comment: strconv.Itoa(i)}))
+	}
+	rotated, err := filepath.Glob(filepath.Join(dir, "calls-*.har"))
+	require.NoError(t, err)
+	assert.Len(t, rotated, 4, "rotations within a millisecond don't overwrite each other")
+
+	// moved away by logrotate when full
+	require.NoError(t, os.Rename(path, filepath.Join(dir, "moved.har")))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, rec.Record(HAREntry{Comment: "after move"}))
+	}
+	var har HAR
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &har))
+	assert.Len(t, har.Log.Entries, 1)
+}
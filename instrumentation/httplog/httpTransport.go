@@ -2,10 +2,9 @@ package httplog
 
 import (
 	"net/http"
-	"net/http/httputil"
-	"strings"
+	"net/http/httptrace"
+	"time"
 
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"libs/logger"
@@ -17,24 +16,22 @@ func NewLogTransport(next http.RoundTripper, logReqBody, logRespBody, passContex
 	return &httpLogTransport{
 		nextTransport: next,
 		defaultSetting: settings{
+			Log:          true,
 			Request:      true,
 			RequestBody:  logReqBody,
 			Response:     true,
 			ResponseBody: logRespBody,
 			PassContext:  passContextFurther,
+			Level:        DefaultLevel,
+			Decode:       true,
+			Redact:       logger.DefaultRedactor(),
 		},
 	}
 }
 
-// Option for LogTransportLogging
-type Option interface {
-	Apply(transport *httpLogTransport)
-}
-
 func New(next http.RoundTripper, opts ...Option) *httpLogTransport {
 	t := httpLogTransport{nextTransport: next,
 		defaultSetting: defaultLogSetting,
-		blackList:      make(map[string]settings),
 	}
 
 	for i := range opts {
@@ -44,208 +41,40 @@ func New(next http.RoundTripper, opts ...Option) *httpLogTransport {
 	return &t
 }
 
-type endpoint struct {
-	Method string
-	URL    string
-}
-
-func reqKey(method, path string) string {
-	return strings.ToLower(method + strings.Trim(path, "/"))
-}
-
-func (e *endpoint) toKey() string {
-	return reqKey(e.Method, e.URL)
-}
-
-func buildBlackList(method string, path []string) []endpoint {
-	bl := make([]endpoint, len(path))
-	for i := range path {
-		bl[i] = endpoint{
-			Method: method,
-			URL:    path[i],
-		}
-	}
-
-	return bl
-}
-
-func SkipLog(method string, paths ...string) Option {
-	return &skipOption{blackList: buildBlackList(method, paths)}
-}
-
-type skipOption struct {
-	blackList []endpoint
-}
-
-func (opt *skipOption) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		transport.blackList[key] = settings{
-			Request:      false,
-			RequestBody:  false,
-			Response:     false,
-			ResponseBody: false,
-			PassContext:  false,
-		}
-	}
-}
-
-func SkipReqBody(method string, paths ...string) Option {
-	return &skipReqBody{blackList: buildBlackList(method, paths)}
-}
-
-type skipReqBody struct {
-	blackList []endpoint
-}
-
-func (opt *skipReqBody) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		prev, ok := transport.blackList[key]
-		if !ok {
-			prev = settings{
-				Request:      true,
-				RequestBody:  true,
-				Response:     true,
-				ResponseBody: true,
-				PassContext:  true,
-			}
-		}
-		prev.RequestBody = false
-
-		transport.blackList[key] = prev
-	}
-}
-
-func SkipRespBody(method string, paths ...string) Option {
-	return &skipResponseBody{blackList: buildBlackList(method, paths)}
-}
-
-type skipResponseBody struct {
-	blackList []endpoint
-}
-
-func (opt *skipResponseBody) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		prev, ok := transport.blackList[key]
-		if !ok {
-			prev = settings{
-				Request:      true,
-				RequestBody:  true,
-				Response:     true,
-				ResponseBody: true,
-				PassContext:  true,
-			}
-		}
-		prev.RequestBody = false
-
-		transport.blackList[key] = prev
-	}
-}
-
-func SkipRequest(method string, paths ...string) Option {
-	return &skipRequest{blackList: buildBlackList(method, paths)}
-}
-
-type skipRequest struct {
-	blackList []endpoint
-}
-
-func (opt *skipRequest) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		prev, ok := transport.blackList[key]
-		if !ok {
-			prev = settings{
-				Request:      true,
-				RequestBody:  true,
-				Response:     true,
-				ResponseBody: true,
-				PassContext:  true,
-			}
-		}
-		prev.RequestBody = false
-		prev.Request = false
-
-		transport.blackList[key] = prev
-	}
-}
-
-func SkipResponse(method string, paths ...string) Option {
-	return &skipReqBody{blackList: buildBlackList(method, paths)}
-}
-
-type skipResponse struct {
-	blackList []endpoint
-}
-
-func (opt *skipResponse) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		prev, ok := transport.blackList[key]
-		if !ok {
-			prev = settings{
-				Request:      true,
-				RequestBody:  true,
-				Response:     true,
-				ResponseBody: true,
-				PassContext:  true,
-			}
-		}
-		prev.ResponseBody = false
-		prev.Response = false
-
-		transport.blackList[key] = prev
-	}
-}
-
-func NoContext(method string, paths ...string) Option {
-	return &skipReqBody{blackList: buildBlackList(method, paths)}
-}
-
-type noContextPass struct {
-	blackList []endpoint
-}
-
-func (opt *noContextPass) Apply(transport *httpLogTransport) {
-	for i := range opt.blackList {
-		key := opt.blackList[i].toKey()
-		prev, ok := transport.blackList[key]
-		if !ok {
-			prev = settings{
-				Request:      true,
-				RequestBody:  true,
-				Response:     true,
-				ResponseBody: true,
-				PassContext:  true,
-			}
-		}
-		prev.PassContext = false
-
-		transport.blackList[key] = prev
-	}
-}
-
 type settings struct {
+	Log          bool
 	Request      bool
 	RequestBody  bool
 	Response     bool
 	ResponseBody bool
 	PassContext  bool
+	PassDebug    bool
+	Level        LevelFunc
+	Trace        bool
+	Stream       bool
+	BodyLimit    int
+	Decode       bool
+	Pretty       bool
+	Redact       *logger.Redactor
+	Curl         int
+	HAR          HARRecorder
 }
 
 var defaultLogSetting = settings{
+	Log:          true,
 	Request:      true,
 	RequestBody:  true,
 	Response:     true,
 	ResponseBody: true,
 	PassContext:  true,
+	Level:        DefaultLevel,
+	Decode:       true,
+	Redact:       logger.DefaultRedactor(),
 }
 
 type httpLogTransport struct {
 	nextTransport  http.RoundTripper
-	blackList      map[string]settings
+	rules          []rule
 	defaultSetting settings
 }
 
@@ -253,54 +82,72 @@ func (t *httpLogTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	ctx := req.Context()
 	l := logger.FromCtx(ctx, "httplog")
 
-	setting, ok := t.blackList[reqKey(req.Method, req.URL.Path)]
-	if !ok {
-		setting = t.defaultSetting
-	}
+	setting := t.settingsFor(req)
+	h := nextHop(req)
+	out := outgoing(req, h)
 
 	if setting.PassContext {
-		req.Header.Set(logger.HTTPHeaderProcessID, logger.GetProcessID(ctx))
-		req.Header.Set(logger.HTTPHeaderRequestID, uuid.NewString()) // generates request id header
+		out.Header.Set(logger.HTTPHeaderProcessID, logger.GetProcessID(ctx))
+		out.Header.Set(logger.HTTPHeaderRequestID, h.requestID)
+		if token, ok := logger.GetDebug(ctx); ok && setting.PassDebug {
+			out.Header.Set(logger.HTTPHeaderDebug, token)
+		}
 	}
 
-	fields := make([]zap.Field, 0, 3)
-	errs := make([]error, 0, 2)
+	if !setting.Log {
+		return t.nextTransport.RoundTrip(out)
+	}
+
+	var tm *timings
+	if setting.Trace {
+		tm = newTimings()
+		out = out.WithContext(httptrace.WithClientTrace(out.Context(), tm.clientTrace()))
+	}
+
+	e := &entry{l: l, setting: setting, req: out, fields: h.fields(), start: time.Now(), tm: tm}
 
 	if setting.Request {
-		body, err := httputil.DumpRequestOut(req, setting.RequestBody)
-		if err == nil {
-			fields = append(fields, logger.RequestDump(body))
+		if dump, err := e.dumpRequest(); err == nil {
+			e.fields = append(e.fields, logger.RequestDump(dump))
 		} else {
-			errs = append(errs, err)
+			e.errs = append(e.errs, err)
 		}
 	} else {
-		fields = append(fields, zap.String(logger.RequestDumpKey, "hidden"))
+		e.fields = append(e.fields, zap.String(logger.RequestDumpKey, "hidden"))
 	}
 
-	resp, err := t.nextTransport.RoundTrip(req)
+	resp, err := t.nextTransport.RoundTrip(e.req)
+	e.resp, e.err = resp, err
 	if err != nil {
-		errs = append(errs, err)
+		e.errs = append(e.errs, err)
+	}
+
+	if tm != nil {
+		if resp != nil {
+			tm.setTLS(resp.TLS)
+		}
+		e.fields = append(e.fields, zap.Object("trace", tm))
 	}
 
 	if setting.Response {
 		if resp != nil {
-			body, err := httputil.DumpResponse(resp, setting.ResponseBody)
-			if err == nil {
-				fields = append(fields, logger.ResponseDump(body))
+			if setting.Stream && setting.ResponseBody && streamable(resp) {
+				e.stream(resp)
+				return resp, err
+			}
+			if dump, err := e.dumpResponse(); err == nil {
+				e.fields = append(e.fields, logger.ResponseDump(dump))
 			} else {
-				errs = append(errs, err)
+				e.errs = append(e.errs, err)
 			}
 		} else {
-			fields = append(fields, logger.ResponseDump(nil))
+			e.fields = append(e.fields, logger.ResponseDump(nil))
 		}
 	} else {
-		fields = append(fields, zap.String(logger.ResponseDumpKey, "hidden"))
+		e.fields = append(e.fields, zap.String(logger.ResponseDumpKey, "hidden"))
 	}
 
-	fields = append(fields, zap.Errors("errors", errs))
-	if len(fields) > 0 {
-		l.Debug("http request sent", fields...)
-	}
+	e.write()
 
 	return resp, err
 }
@@ -0,0 +1,47 @@
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"libs/logger"
+)
+
+func TestPassDebug(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(logger.HTTPHeaderDebug))
+	}))
+	defer srv.Close()
+
+	send := func(transport http.RoundTripper, path string) {
+		client := &http.Client{Transport: transport}
+		for _, ctx := range []context.Context{context.Background(), logger.BindDebug(context.Background(), "token")} {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+		}
+	}
+
+	send(New(http.DefaultTransport), "/")
+	assert.Equal(t, []string{"", ""}, got, "not sent by default")
+
+	got = nil
+	internal := New(http.DefaultTransport,
+		Host(srv.Listener.Addr().String(), PassDebug(), NoContext(http.MethodGet, "/partner")),
+		Host("other.local", PassDebug()),
+	)
+	send(internal, "/internal")
+	send(internal, "/partner")
+	assert.Equal(t, []string{"", "token", "", ""}, got, "sent to enabled host with logging context only")
+
+	got = nil
+	send(New(http.DefaultTransport, Host("other.local", PassDebug())), "/")
+	assert.Equal(t, []string{"", ""}, got, "not sent to other hosts")
+}
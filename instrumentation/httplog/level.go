@@ -0,0 +1,57 @@
+package httplog
+
+import (
+	"net/http"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelFunc classifies an outgoing request by its outcome. resp is nil whenever err is not nil.
+type LevelFunc func(req *http.Request, resp *http.Response, err error) zapcore.Level
+
+// DefaultLevel logs transport failures at error, 5xx at warn, 4xx at info and everything else at debug
+func DefaultLevel(_ *http.Request, resp *http.Response, err error) zapcore.Level {
+	switch {
+	case err != nil || resp == nil:
+		return zapcore.ErrorLevel
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return zapcore.WarnLevel
+	case resp.StatusCode >= http.StatusBadRequest:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// FixedLevel returns LevelFunc that logs every request at level regardless of its outcome
+func FixedLevel(level zapcore.Level) LevelFunc {
+	return func(*http.Request, *http.Response, error) zapcore.Level {
+		return level
+	}
+}
+
+// WithLevel replaces DefaultLevel for all endpoints without their own classifier
+func WithLevel(fn LevelFunc) Option {
+	return &patchOption{patch: setLevel(fn)}
+}
+
+// EndpointLevel sets classifier for particular endpoints only
+// e.g. EndpointLevel(FixedLevel(zapcore.InfoLevel), http.MethodPost, "/payments")
+func EndpointLevel(fn LevelFunc, method string, paths ...string) Option {
+	return &patchOption{patch: setLevel(fn), blackList: buildBlackList(method, paths)}
+}
+
+func setLevel(fn LevelFunc) patch {
+	return func(s *settings) {
+		s.Level = fn
+	}
+}
+
+// level classifies request with endpoint classifier
+func (s *settings) level(req *http.Request, resp *http.Response, err error) zapcore.Level {
+	if s.Level == nil {
+		return DefaultLevel(req, resp, err)
+	}
+
+	return s.Level(req, resp, err)
+}
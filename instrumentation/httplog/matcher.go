@@ -0,0 +1,245 @@
+package httplog
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// endpoint is a pattern parsed from the path argument of options. Every option taking
+// (method string, paths ...string) accepts these patterns:
+//
+//	"/clients/list"                  exact path, case-insensitive, surrounding slashes ignored
+//	"/clients/:id/accounts"          ":name" matches exactly one path segment
+//	"/files/*" or "/files/*rest"     trailing "*" matches the rest of the path, including nothing
+//	"^/clients/\d+/accounts$"        regular expression matched against URL.Path as is
+//	"//api.example.com/clients/:id"  host is matched too ("https://" prefix is accepted, scheme is ignored)
+//	"//*.example.com"                any subdomain of example.com, any path
+//
+// method "" or "*" matches every method.
+//
+// When several patterns match a request the most specific one wins, compared in this order:
+// host (exact > wildcard > any), path (exact > template > regexp > any),
+// number of literal segments, number of ":name" segments, templates without trailing "*",
+// method (exact > any). Equal patterns are resolved in favour of the one declared later.
+type endpoint struct {
+	Method string
+	URL    string
+
+	host     string
+	kind     pathKind
+	segments []string
+	re       *regexp.Regexp
+	seq      int
+}
+
+type pathKind int
+
+const (
+	pathAny pathKind = iota
+	pathRegexp
+	pathTemplate
+	pathExact
+)
+
+const (
+	hostAny = iota
+	hostWildcard
+	hostExact
+)
+
+// newEndpoint parses pattern. It panics on invalid regular expression as regexp.MustCompile does,
+// options are expected to be built once on application bootstrap.
+func newEndpoint(method, pattern string) endpoint {
+	e := endpoint{Method: strings.ToUpper(method), URL: pattern}
+	if e.Method == "*" {
+		e.Method = ""
+	}
+
+	path := pattern
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+1:]
+	}
+	if strings.HasPrefix(path, "//") {
+		path = path[2:]
+		host := path
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			host, path = path[:i], path[i:]
+		} else {
+			path = ""
+		}
+		e.host = strings.ToLower(host)
+	}
+
+	switch {
+	case strings.HasPrefix(path, "^"):
+		e.kind = pathRegexp
+		e.re = regexp.MustCompile(path)
+	case e.host != "" && path == "":
+		e.kind = pathAny
+	default:
+		e.kind = pathExact
+		e.segments = splitPath(strings.ToLower(path))
+		for _, s := range e.segments {
+			if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+				e.kind = pathTemplate
+			}
+		}
+	}
+
+	return e
+}
+
+func buildBlackList(method string, path []string) []endpoint {
+	bl := make([]endpoint, len(path))
+	for i := range path {
+		bl[i] = newEndpoint(method, path[i])
+	}
+
+	return bl
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func (e *endpoint) match(req *http.Request) bool {
+	if e.Method != "" && e.Method != req.Method {
+		return false
+	}
+	if e.host != "" && !matchHost(e.host, req) {
+		return false
+	}
+
+	switch e.kind {
+	case pathRegexp:
+		return e.re.MatchString(req.URL.Path)
+	case pathExact, pathTemplate:
+		return matchSegments(e.segments, splitPath(strings.ToLower(req.URL.Path)))
+	default:
+		return true
+	}
+}
+
+func matchHost(pattern string, req *http.Request) bool {
+	host := req.URL.Host
+	if host == "" {
+		host = req.Host
+	}
+	host = strings.ToLower(host)
+	if !strings.Contains(pattern, ":") {
+		if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:i]
+		}
+	}
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return host == pattern
+}
+
+func matchSegments(pattern, path []string) bool {
+	for i, s := range pattern {
+		if strings.HasPrefix(s, "*") {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(s, ":") && s != path[i] {
+			return false
+		}
+	}
+
+	return len(pattern) == len(path)
+}
+
+// rank orders endpoints by specificity, see package level pattern description
+func (e *endpoint) rank() [6]int {
+	var r [6]int
+	switch {
+	case e.host == "":
+		r[0] = hostAny
+	case strings.HasPrefix(e.host, "*."):
+		r[0] = hostWildcard
+	default:
+		r[0] = hostExact
+	}
+	r[1] = int(e.kind)
+	r[4] = 1
+	for _, s := range e.segments {
+		switch {
+		case strings.HasPrefix(s, "*"):
+			r[4] = 0
+		case strings.HasPrefix(s, ":"):
+			r[3]++
+		default:
+			r[2]++
+		}
+	}
+	if e.Method != "" {
+		r[5] = 1
+	}
+
+	return r
+}
+
+// moreSpecific reports whether e takes precedence over other
+func (e *endpoint) moreSpecific(other *endpoint) bool {
+	a, b := e.rank(), other.rank()
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+
+	return e.seq > other.seq
+}
+
+// rule is a list of patches applied to requests matching endpoint
+type rule struct {
+	endpoint
+	patches []patch
+}
+
+// add appends a rule, remembering the order rules were declared in
+func (t *httpLogTransport) add(e endpoint, patches ...patch) {
+	e.seq = len(t.rules)
+	t.rules = append(t.rules, rule{endpoint: e, patches: patches})
+}
+
+// match returns rules matching req, the most specific one last
+func (t *httpLogTransport) match(req *http.Request) []*rule {
+	matched := make([]*rule, 0, len(t.rules))
+	for i := range t.rules {
+		if t.rules[i].match(req) {
+			matched = append(matched, &t.rules[i])
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[j].moreSpecific(&matched[i].endpoint)
+	})
+
+	return matched
+}
+
+// settingsFor applies patches of every rule matching req to defaultSetting,
+// so the most specific rule has the last word
+func (t *httpLogTransport) settingsFor(req *http.Request) settings {
+	s := t.defaultSetting
+	for _, r := range t.match(req) {
+		for _, p := range r.patches {
+			p(&s)
+		}
+	}
+
+	return s
+}
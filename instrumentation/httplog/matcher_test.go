@@ -0,0 +1,157 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointMatch(t *testing.T) {
+	type args struct {
+		method  string
+		pattern string
+		req     *http.Request
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"exact", args{http.MethodGet, "/clients/list", get("http://api.local/clients/list")}, true},
+		{"exact surrounding slashes", args{http.MethodGet, "clients/list/", get("http://api.local/clients/list")}, true},
+		{"exact case-insensitive", args{http.MethodGet, "/Clients/List", get("http://api.local/clients/LIST")}, true},
+		{"exact other path", args{http.MethodGet, "/clients/list", get("http://api.local/clients/list/1")}, false},
+		{"exact root", args{http.MethodGet, "/", get("http://api.local/")}, true},
+		{"method mismatch", args{http.MethodPost, "/clients/list", get("http://api.local/clients/list")}, false},
+		{"method lower case", args{"get", "/clients/list", get("http://api.local/clients/list")}, true},
+		{"method wildcard", args{"*", "/clients/list", get("http://api.local/clients/list")}, true},
+		{"method empty", args{"", "/clients/list", get("http://api.local/clients/list")}, true},
+		{"param", args{http.MethodGet, "/clients/:id/accounts", get("http://api.local/clients/123/accounts")}, true},
+		{"param one segment only", args{http.MethodGet, "/clients/:id/accounts", get("http://api.local/clients/1/2/accounts")}, false},
+		{"param missing", args{http.MethodGet, "/clients/:id", get("http://api.local/clients")}, false},
+		{"wildcard rest", args{http.MethodGet, "/clients/:id/*", get("http://api.local/clients/1/accounts/2")}, true},
+		{"wildcard empty rest", args{http.MethodGet, "/clients/:id/*rest", get("http://api.local/clients/1")}, true},
+		{"wildcard prefix mismatch", args{http.MethodGet, "/clients/*", get("http://api.local/prospects/1")}, false},
+		{"regexp", args{http.MethodGet, `^/clients/\d+/accounts$`, get("http://api.local/clients/123/accounts")}, true},
+		{"regexp mismatch", args{http.MethodGet, `^/clients/\d+/accounts$`, get("http://api.local/clients/abc/accounts")}, false},
+		{"regexp ignores query", args{http.MethodGet, `^/search$`, get("http://api.local/search?q=1")}, true},
+		{"host", args{http.MethodGet, "//api.local/clients/:id", get("http://api.local/clients/1")}, true},
+		{"host with scheme", args{http.MethodGet, "https://api.local/clients/:id", get("http://api.local:8080/clients/1")}, true},
+		{"host other", args{http.MethodGet, "//api.local/clients/:id", get("http://iabs.local/clients/1")}, false},
+		{"host port", args{http.MethodGet, "//api.local:8080", get("http://api.local:8080/anything")}, true},
+		{"host other port", args{http.MethodGet, "//api.local:8080", get("http://api.local:9090/anything")}, false},
+		{"host any path", args{http.MethodGet, "//api.local", get("http://api.local/clients/1")}, true},
+		{"host wildcard", args{http.MethodGet, "//*.bank.uz", get("http://esb.bank.uz/call")}, true},
+		{"host wildcard no apex", args{http.MethodGet, "//*.bank.uz", get("http://bank.uz/call")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEndpoint(tt.args.method, tt.args.pattern)
+			assert.Equal(t, tt.want, e.match(tt.args.req))
+		})
+	}
+}
+
+func TestEndpointPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns [][2]string // {method, pattern} in declaration order
+		req      *http.Request
+		want     string
+	}{
+		{
+			"exact over template",
+			[][2]string{{"GET", "/clients/:id"}, {"GET", "/clients/me"}},
+			get("http://api.local/clients/me"),
+			"/clients/me",
+		},
+		{
+			"template over regexp",
+			[][2]string{{"GET", "/clients/:id"}, {"GET", `^/clients/.*$`}},
+			get("http://api.local/clients/1"),
+			"/clients/:id",
+		},
+		{
+			"more literal segments",
+			[][2]string{{"GET", "/clients/:id/*"}, {"GET", "/clients/:id/accounts"}},
+			get("http://api.local/clients/1/accounts"),
+			"/clients/:id/accounts",
+		},
+		{
+			"param over wildcard",
+			[][2]string{{"GET", "/clients/:id"}, {"GET", "/clients/*"}},
+			get("http://api.local/clients/1"),
+			"/clients/:id",
+		},
+		{
+			"host over path",
+			[][2]string{{"GET", "//api.local"}, {"GET", "/clients/1"}},
+			get("http://api.local/clients/1"),
+			"//api.local",
+		},
+		{
+			"exact host over wildcard host",
+			[][2]string{{"GET", "//api.local"}, {"GET", "//*.local"}},
+			get("http://api.local/clients/1"),
+			"//api.local",
+		},
+		{
+			"exact method over wildcard",
+			[][2]string{{"GET", "/clients"}, {"*", "/clients"}},
+			get("http://api.local/clients"),
+			"/clients",
+		},
+		{
+			"later declared wins a tie",
+			[][2]string{{"GET", `^/clients`}, {"GET", `^/clients/\d+$`}},
+			get("http://api.local/clients/1"),
+			`^/clients/\d+$`,
+		},
+		{
+			"no match",
+			[][2]string{{"POST", "/clients"}},
+			get("http://api.local/clients"),
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := New(http.DefaultTransport)
+			for _, p := range tt.patterns {
+				transport.add(newEndpoint(p[0], p[1]))
+			}
+
+			var got string
+			if matched := transport.match(tt.req); len(matched) > 0 {
+				got = matched[len(matched)-1].URL
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSettingsFor(t *testing.T) {
+	transport := New(http.DefaultTransport,
+		SkipLog(http.MethodGet, "/health"),
+		SkipReqBody(http.MethodPost, "/clients/:id/documents"),
+		SkipRequest("*", "//secret.local"),
+	)
+
+	assert.False(t, transport.settingsFor(get("http://api.local/health")).Request)
+	assert.False(t, transport.settingsFor(post("http://api.local/clients/7/documents")).RequestBody)
+	assert.True(t, transport.settingsFor(post("http://api.local/clients/7/documents")).Request)
+	assert.False(t, transport.settingsFor(post("http://secret.local/clients/7/documents")).Request)
+	assert.Equal(t, defaultLogSetting.RequestBody, transport.settingsFor(post("http://api.local/clients")).RequestBody)
+}
+
+func get(url string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, url, nil)
+}
+
+func post(url string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, url, nil)
+}
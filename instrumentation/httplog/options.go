@@ -0,0 +1,190 @@
+package httplog
+
+import (
+	"sync"
+
+	"libs/logger"
+)
+
+// Option for LogTransportLogging
+type Option interface {
+	Apply(transport *httpLogTransport)
+}
+
+// patch changes settings of a request. Patches of all rules matching a request are applied
+// to the transport default settings, from the least specific rule to the most specific one
+// and in declaration order for equally specific rules.
+type patch func(*settings)
+
+// patchOption applies patch to every request if blackList is empty, otherwise to the listed endpoints only
+type patchOption struct {
+	blackList []endpoint
+	patch     patch
+}
+
+func (opt *patchOption) Apply(transport *httpLogTransport) {
+	if opt.blackList == nil {
+		transport.add(endpoint{}, opt.patch)
+		return
+	}
+
+	for i := range opt.blackList {
+		transport.add(opt.blackList[i], opt.patch)
+	}
+}
+
+// SkipLog disables logging of endpoints and passing logging context to them
+func SkipLog(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.Log = false
+		s.Request = false
+		s.RequestBody = false
+		s.Response = false
+		s.ResponseBody = false
+		s.PassContext = false
+	}}
+}
+
+// SkipReqBody logs request of endpoints without its body
+func SkipReqBody(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.RequestBody = false
+	}}
+}
+
+// SkipRespBody logs response of endpoints without its body
+func SkipRespBody(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.ResponseBody = false
+	}}
+}
+
+// SkipRequest hides request dump of endpoints
+func SkipRequest(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.Request = false
+		s.RequestBody = false
+	}}
+}
+
+// SkipResponse hides response dump of endpoints
+func SkipResponse(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.Response = false
+		s.ResponseBody = false
+	}}
+}
+
+// NoContext doesn't send process_id/request_id headers to endpoints
+func NoContext(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.PassContext = false
+	}}
+}
+
+// PassDebug sends debug token of the context (see logger.BindDebug) to upstreams along with logging context,
+// so they log the same flow at debug level. A token stays valid until it expires, so enable it for internal
+// upstreams only, inside Host:
+//
+//	httplog.Host("*.bank.local", httplog.PassDebug())
+func PassDebug() Option {
+	return &patchOption{patch: func(s *settings) {
+		s.PassDebug = true
+	}}
+}
+
+// Allowlist turns logging off for every request except endpoints enabled by Allow.
+// Used inside Host it affects that host only. Logging context is still passed, see NoContext
+func Allowlist() Option {
+	return &patchOption{patch: func(s *settings) {
+		s.Log = false
+		s.Request = false
+		s.RequestBody = false
+		s.Response = false
+		s.ResponseBody = false
+	}}
+}
+
+// Allow enables full logging of endpoints, undoing less specific options.
+// Combine with SkipReqBody/SkipRespBody of the same endpoints to log headers only
+func Allow(method string, paths ...string) Option {
+	return &patchOption{blackList: buildBlackList(method, paths), patch: func(s *settings) {
+		s.Log = true
+		s.Request = true
+		s.RequestBody = true
+		s.Response = true
+		s.ResponseBody = true
+	}}
+}
+
+// Host is a settings profile of one upstream: opts apply only to requests sent to host.
+// host is matched as in path patterns: "api.example.com", "api.example.com:8443" or "*.example.com".
+// Options without endpoints apply to every request of the host, paths of endpoint options are
+// matched on the host unless they name a host themselves. Rules of a host profile are more
+// specific than any rule without host, so one transport may serve several upstreams:
+//
+//	httplog.New(http.DefaultTransport,
+//		httplog.SkipReqBody("*", "*"), httplog.SkipRespBody("*", "*"), // headers only by default
+//		httplog.Host("esb.bank.uz", httplog.Allow("*", "*")),          // full bodies
+//		httplog.Host("*.partner.uz", httplog.Allowlist(), httplog.Allow(http.MethodPost, "/payments")),
+//	)
+func Host(host string, opts ...Option) Option {
+	return &hostOption{host: newEndpoint("", "//"+host).host, opts: opts}
+}
+
+type hostOption struct {
+	host string
+	opts []Option
+}
+
+func (opt *hostOption) Apply(transport *httpLogTransport) {
+	var profile httpLogTransport
+	for i := range opt.opts {
+		opt.opts[i].Apply(&profile)
+	}
+
+	for _, r := range profile.rules {
+		if r.host == "" {
+			r.host = opt.host
+		}
+		transport.add(r.endpoint, r.patches...)
+	}
+}
+
+// RedactHeaders masks values of headers in addition to logger.DefaultSensitiveHeaders
+// in dumps and curl commands, names are case-insensitive
+func RedactHeaders(names ...string) Option {
+	return &patchOption{patch: extendRedactor(func(r *logger.Redactor) *logger.Redactor {
+		return r.WithHeaders(names...)
+	})}
+}
+
+// RedactFields masks values of JSON and urlencoded form fields in logged bodies, curl commands and HAR entries,
+// names are case-insensitive
+func RedactFields(names ...string) Option {
+	return &patchOption{patch: extendRedactor(func(r *logger.Redactor) *logger.Redactor {
+		return r.WithFields(names...)
+	})}
+}
+
+// extendRedactor returns patch replacing Redactor of settings with extend of it. Redactors are immutable
+// and requests share a few of them, so each one is extended once, not on every request
+func extendRedactor(extend func(*logger.Redactor) *logger.Redactor) patch {
+	var extended sync.Map // *logger.Redactor to its extension
+
+	return func(s *settings) {
+		if r, ok := extended.Load(s.Redact); ok {
+			s.Redact = r.(*logger.Redactor)
+			return
+		}
+		r, _ := extended.LoadOrStore(s.Redact, extend(s.Redact))
+		s.Redact = r.(*logger.Redactor)
+	}
+}
+
+// WithRedactor replaces redaction rules, nil Redactor masks nothing
+func WithRedactor(r *logger.Redactor) Option {
+	return &patchOption{patch: func(s *settings) {
+		s.Redact = r
+	}}
+}
@@ -0,0 +1,223 @@
+package httplog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"libs/logger"
+)
+
+// flags is comparable view of settings
+type flags struct {
+	Log, Request, RequestBody, Response, ResponseBody, PassContext bool
+}
+
+func flagsOf(s settings) flags {
+	return flags{s.Log, s.Request, s.RequestBody, s.Response, s.ResponseBody, s.PassContext}
+}
+
+var allOn = flags{true, true, true, true, true, true}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option func(method string, paths ...string) Option
+		want   flags
+	}{
+		{"SkipLog", SkipLog, flags{}},
+		{"SkipReqBody", SkipReqBody, flags{true, true, false, true, true, true}},
+		{"SkipRespBody", SkipRespBody, flags{true, true, true, true, false, true}},
+		{"SkipRequest", SkipRequest, flags{true, false, false, true, true, true}},
+		{"SkipResponse", SkipResponse, flags{true, true, true, false, false, true}},
+		{"NoContext", NoContext, flags{true, true, true, true, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := New(http.DefaultTransport, tt.option(http.MethodPost, "/clients/:id"))
+
+			assert.Equal(t, tt.want, flagsOf(transport.settingsFor(post("http://api.local/clients/1"))))
+			assert.Equal(t, allOn, flagsOf(transport.settingsFor(get("http://api.local/clients/1"))), "other method")
+			assert.Equal(t, allOn, flagsOf(transport.settingsFor(post("http://api.local/clients"))), "other path")
+		})
+	}
+}
+
+// TestOptionCombinations checks every subset of options in both declaration orders:
+// options only switch things off, so the result is the conjunction of each option alone
+func TestOptionCombinations(t *testing.T) {
+	options := []func(method string, paths ...string) Option{
+		SkipLog, SkipReqBody, SkipRespBody, SkipRequest, SkipResponse, NoContext,
+	}
+	req := post("http://api.local/clients/1")
+
+	alone := make([]flags, len(options))
+	for i, o := range options {
+		alone[i] = flagsOf(New(http.DefaultTransport, o(http.MethodPost, "/clients/1")).settingsFor(req))
+	}
+
+	for mask := 1; mask < 1<<len(options); mask++ {
+		var forward, backward []Option
+		want := allOn
+		for i := range options {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			// endpoints of different specificity, all matching req
+			paths := []string{"/clients/1", "/clients/:id", "/clients/*", `^/clients/\d+$`, "//api.local", "*"}
+			o := options[i](http.MethodPost, paths[i%len(paths)])
+			forward = append(forward, o)
+			backward = append([]Option{o}, backward...)
+			want = flags{
+				want.Log && alone[i].Log,
+				want.Request && alone[i].Request,
+				want.RequestBody && alone[i].RequestBody,
+				want.Response && alone[i].Response,
+				want.ResponseBody && alone[i].ResponseBody,
+				want.PassContext && alone[i].PassContext,
+			}
+		}
+
+		assert.Equal(t, want, flagsOf(New(http.DefaultTransport, forward...).settingsFor(req)), "mask %06b", mask)
+		assert.Equal(t, want, flagsOf(New(http.DefaultTransport, backward...).settingsFor(req)), "mask %06b reversed", mask)
+	}
+}
+
+func TestOptionsStartFromDefaults(t *testing.T) {
+	transport := New(http.DefaultTransport, SkipReqBody(http.MethodGet, "/a"))
+	transport.defaultSetting.ResponseBody = false
+	transport.defaultSetting.PassContext = false
+
+	assert.Equal(t, flags{true, true, false, true, false, false}, flagsOf(transport.settingsFor(get("http://api.local/a"))))
+}
+
+func TestLevelOptions(t *testing.T) {
+	req := get("http://api.local/clients/1/accounts")
+	ok := &http.Response{StatusCode: http.StatusOK}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want zapcore.Level
+	}{
+		{"default", nil, zapcore.DebugLevel},
+		{"global", []Option{WithLevel(FixedLevel(zapcore.InfoLevel))}, zapcore.InfoLevel},
+		{
+			"endpoint over global",
+			[]Option{
+				EndpointLevel(FixedLevel(zapcore.WarnLevel), http.MethodGet, "/clients/:id/*"),
+				WithLevel(FixedLevel(zapcore.InfoLevel)),
+			},
+			zapcore.WarnLevel,
+		},
+		{
+			"more specific endpoint declared first",
+			[]Option{
+				EndpointLevel(FixedLevel(zapcore.ErrorLevel), http.MethodGet, "/clients/:id/accounts"),
+				EndpointLevel(FixedLevel(zapcore.WarnLevel), http.MethodGet, "/clients/:id/*"),
+			},
+			zapcore.ErrorLevel,
+		},
+		{
+			"equal endpoints, later wins",
+			[]Option{
+				EndpointLevel(FixedLevel(zapcore.ErrorLevel), http.MethodGet, "/clients/:id/accounts"),
+				EndpointLevel(FixedLevel(zapcore.WarnLevel), http.MethodGet, "/clients/:x/accounts"),
+			},
+			zapcore.WarnLevel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(http.DefaultTransport, tt.opts...).settingsFor(req)
+			assert.Equal(t, tt.want, s.level(req, ok, nil))
+		})
+	}
+}
+
+func TestDefaultLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   zapcore.Level
+	}{
+		{"transport error", 0, http.ErrHandlerTimeout, zapcore.ErrorLevel},
+		{"5xx", http.StatusBadGateway, nil, zapcore.WarnLevel},
+		{"4xx", http.StatusNotFound, nil, zapcore.InfoLevel},
+		{"3xx", http.StatusFound, nil, zapcore.DebugLevel},
+		{"2xx", http.StatusOK, nil, zapcore.DebugLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			assert.Equal(t, tt.want, DefaultLevel(get("http://api.local"), resp, tt.err))
+		})
+	}
+}
+
+func TestHostProfiles(t *testing.T) {
+	transport := New(http.DefaultTransport,
+		SkipReqBody("*", "*"),
+		SkipRespBody("*", "*"),
+		Host("esb.bank.uz", Allow("*", "*"), SkipLog(http.MethodGet, "/health")),
+		Host("*.partner.uz", Allowlist(), Allow(http.MethodPost, "/payments/:id"), NoContext("*", "*")),
+		Host("iabs.local:8443", WithLevel(FixedLevel(zapcore.WarnLevel))),
+	)
+	headersOnly := flags{true, true, false, true, false, true}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want flags
+	}{
+		{"headers only by default", post("http://other.local/payments/1"), headersOnly},
+		{"full bodies for host", post("http://esb.bank.uz/clients"), allOn},
+		{"endpoint inside host", get("http://esb.bank.uz/health"), flags{}},
+		{"same path on other host", get("http://other.local/health"), headersOnly},
+		{"allowlisted host, allowed endpoint", post("http://api.partner.uz/payments/1"), flags{true, true, true, true, true, false}},
+		{"allowlisted host, other endpoint", get("http://api.partner.uz/payments/1"), flags{false, false, false, false, false, false}},
+		{"host port", get("http://iabs.local:8443/clients"), headersOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, flagsOf(transport.settingsFor(tt.req)))
+		})
+	}
+
+	ok := &http.Response{StatusCode: http.StatusOK}
+	req := get("http://iabs.local:8443/clients")
+	s := transport.settingsFor(req)
+	assert.Equal(t, zapcore.WarnLevel, s.level(req, ok, nil))
+	req = get("http://iabs.local/clients")
+	s = transport.settingsFor(req)
+	assert.Equal(t, zapcore.DebugLevel, s.level(req, ok, nil))
+}
+
+func TestAllowlist(t *testing.T) {
+	transport := New(http.DefaultTransport, Allowlist(), Allow(http.MethodPost, "/payments"), SkipRespBody(http.MethodPost, "/payments"))
+
+	assert.Equal(t, flags{true, true, true, true, false, true}, flagsOf(transport.settingsFor(post("http://api.local/payments"))))
+	assert.Equal(t, flags{false, false, false, false, false, true}, flagsOf(transport.settingsFor(get("http://api.local/payments"))))
+}
+
+func TestRedactHeaders(t *testing.T) {
+	transport := New(http.DefaultTransport, RedactHeaders("X-Token"), Host("api.partner.uz", WithRedactor(logger.NewRedactor())))
+
+	s := transport.settingsFor(get("http://api.local/a"))
+	assert.True(t, s.Redact.SensitiveHeader("x-token"))
+	assert.True(t, s.Redact.SensitiveHeader("Authorization"))
+	assert.Same(t, s.Redact, transport.settingsFor(get("http://api.local/b")).Redact, "built once")
+
+	s = transport.settingsFor(get("http://api.partner.uz/a"))
+	assert.False(t, s.Redact.SensitiveHeader("x-token"), "host redactor replaces the global one")
+}
@@ -0,0 +1,129 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"libs/logger"
+)
+
+// DefaultBodyLimit is the number of response body bytes captured by streaming options when limit is not positive
+const DefaultBodyLimit = 64 << 10
+
+// WithStreaming logs response bodies of every request as they are read by the caller, see EndpointStreaming
+func WithStreaming(limit int) Option {
+	return &patchOption{patch: enableStreaming(limit)}
+}
+
+// EndpointStreaming logs response bodies of endpoints as they are read by the caller instead of
+// buffering them before RoundTrip returns. Up to limit bytes are captured, the entry is written
+// when the caller closes response body with total bytes read, read duration and read error.
+// Bodies that are never closed are never logged.
+func EndpointStreaming(limit int, method string, paths ...string) Option {
+	return &patchOption{patch: enableStreaming(limit), blackList: buildBlackList(method, paths)}
+}
+
+func enableStreaming(limit int) patch {
+	if limit <= 0 {
+		limit = DefaultBodyLimit
+	}
+
+	return func(s *settings) {
+		s.Stream = true
+		s.BodyLimit = limit
+	}
+}
+
+// streamable excludes bodies which are not plain readers, e.g. 101 Switching Protocols
+func streamable(resp *http.Response) bool {
+	return resp.Body != nil && resp.Body != http.NoBody && resp.StatusCode != http.StatusSwitchingProtocols
+}
+
+// stream replaces resp.Body with capturing reader, writing the entry when it is closed
+func (e *entry) stream(resp *http.Response) {
+	redacted := *resp
+	redacted.Header = e.setting.Redact.Header(resp.Header)
+	head, err := httputil.DumpResponse(&redacted, false)
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+
+	resp.Body = &capturedBody{
+		ReadCloser: resp.Body,
+		limit:      e.setting.BodyLimit,
+		start:      time.Now(),
+		done: func(c *capturedBody) {
+			e.end = time.Now()
+			e.respBody = c.buf.Bytes()
+			e.fields = append(e.fields,
+				logger.ResponseDump(append(head, formatBody(resp.Header, c.buf.Bytes(), e.setting)...)),
+				zap.Int64("response_body_bytes", c.total),
+				zap.Duration("response_read_duration", time.Since(c.start)),
+			)
+			if c.err != nil {
+				e.fields = append(e.fields, zap.NamedError("response_read_error", c.err))
+			}
+			e.write()
+		},
+	}
+}
+
+// capturedBody tees up to limit bytes into buf while the caller reads.
+// Close may be called by another goroutine to abort reading, so capture state is guarded by mutex
+// and reads returning after Close are not captured
+type capturedBody struct {
+	io.ReadCloser
+	limit int
+	start time.Time
+	done  func(*capturedBody)
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	total  int64
+	err    error
+	closed bool
+}
+
+func (c *capturedBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return n, err
+	}
+	if n > 0 {
+		c.total += int64(n)
+		if rest := c.limit - c.buf.Len(); rest > 0 {
+			if rest > n {
+				rest = n
+			}
+			c.buf.Write(p[:rest])
+		}
+	}
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+
+	return n, err
+}
+
+// Close closes the body and calls done once
+func (c *capturedBody) Close() error {
+	err := c.ReadCloser.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.done(c)
+	}
+
+	return err
+}
@@ -0,0 +1,108 @@
+package httplog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapturedBody(t *testing.T) {
+	errRead := errors.New("connection reset")
+	tests := []struct {
+		name     string
+		body     io.Reader
+		limit    int
+		wantBuf  string
+		wantN    int64
+		wantErr  error
+		readFull bool
+	}{
+		{"under limit", strings.NewReader("hello"), 10, "hello", 5, nil, true},
+		{"over limit", strings.NewReader("hello world"), 5, "hello", 11, nil, true},
+		{"small reads", iotest.OneByteReader(strings.NewReader("hello world")), 7, "hello w", 11, nil, true},
+		{"read error", io.MultiReader(strings.NewReader("hel"), iotest.ErrReader(errRead)), 10, "hel", 3, errRead, true},
+		{"closed early", strings.NewReader("hello world"), 10, "", 0, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := &capturedBody{ReadCloser: io.NopCloser(tt.body), limit: tt.limit, done: func(*capturedBody) { calls++ }}
+			if tt.readFull {
+				_, _ = io.ReadAll(c)
+			}
+			assert.NoError(t, c.Close())
+			assert.NoError(t, c.Close())
+
+			assert.Equal(t, 1, calls)
+			assert.Equal(t, tt.wantBuf, c.buf.String())
+			assert.Equal(t, tt.wantN, c.total)
+			assert.Equal(t, tt.wantErr, c.err)
+		})
+	}
+}
+
+func TestCapturedBodyConcurrentClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		for {
+			if _, err := pw.Write([]byte("chunk")); err != nil {
+				return
+			}
+		}
+	}()
+
+	var captured string
+	c := &capturedBody{ReadCloser: pr, limit: 1 << 20, done: func(c *capturedBody) { captured = c.buf.String() }}
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		_, _ = io.Copy(io.Discard, c)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, c.Close(), "aborts the stream from another goroutine")
+	<-read
+
+	assert.NotEmpty(t, captured)
+	assert.Equal(t, captured, c.buf.String(), "reads after Close are not captured")
+}
+
+func TestStreamingTransport(t *testing.T) {
+	logs := observe(t)
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := New(http.DefaultTransport, WithStreaming(15)).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Zero(t, logs.Len(), "logged when the body is closed")
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(raw), "caller reads the whole body")
+	assert.Zero(t, logs.Len())
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, resp.Body.Close())
+
+	entries := logs.All()
+	require.Len(t, entries, 1, "logged once")
+	fields := entries[0].ContextMap()
+	dump := fields["response_dump"].(string)
+	assert.True(t, strings.HasPrefix(dump, "HTTP/1.1 200 OK"), dump)
+	assert.True(t, strings.HasSuffix(dump, "\r\n\r\n012345678901234"), "prefix up to the limit: %q", dump)
+	assert.EqualValues(t, len(body), fields["response_body_bytes"])
+	assert.Contains(t, fields, "response_read_duration")
+	assert.NotContains(t, fields, "response_read_error")
+}
@@ -0,0 +1,158 @@
+package httplog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithTrace records connection phase timings of every request, see EndpointTrace
+func WithTrace() Option {
+	return &patchOption{patch: enableTrace}
+}
+
+// EndpointTrace records connection phase timings of endpoints requests:
+// dns, connect, tls handshake and waiting for the first response byte,
+// whether connection was reused from the pool, remote address and negotiated TLS version/cipher.
+// Timings are logged under "trace" key
+func EndpointTrace(method string, paths ...string) Option {
+	return &patchOption{patch: enableTrace, blackList: buildBlackList(method, paths)}
+}
+
+func enableTrace(s *settings) {
+	s.Trace = true
+}
+
+// timings collects httptrace.ClientTrace events of one request.
+// Dialing may happen in another goroutine, so events are guarded by mutex
+type timings struct {
+	mu sync.Mutex
+
+	start        time.Time
+	getConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	reused     bool
+	wasIdle    bool
+	idleTime   time.Duration
+	remoteAddr string
+	tls        *tls.ConnectionState
+}
+
+func newTimings() *timings {
+	return &timings{start: time.Now()}
+}
+
+func (tm *timings) clientTrace() *httptrace.ClientTrace {
+	now := func(t *time.Time) {
+		tm.mu.Lock()
+		*t = time.Now()
+		tm.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn:  func(string) { now(&tm.getConn) },
+		DNSStart: func(httptrace.DNSStartInfo) { now(&tm.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(&tm.dnsDone) },
+		ConnectStart: func(string, string) {
+			tm.mu.Lock()
+			if tm.connectStart.IsZero() { // several addresses may be dialed in parallel
+				tm.connectStart = time.Now()
+			}
+			tm.mu.Unlock()
+		},
+		ConnectDone:       func(string, string, error) { now(&tm.connectDone) },
+		TLSHandshakeStart: func() { now(&tm.tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			tm.mu.Lock()
+			tm.tlsDone = time.Now()
+			if err == nil {
+				tm.tls = &state
+			}
+			tm.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tm.mu.Lock()
+			tm.gotConn = time.Now()
+			tm.reused = info.Reused
+			tm.wasIdle = info.WasIdle
+			tm.idleTime = info.IdleTime
+			if info.Conn != nil && info.Conn.RemoteAddr() != nil {
+				tm.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			tm.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { now(&tm.wroteRequest) },
+		GotFirstResponseByte: func() { now(&tm.firstByte) },
+	}
+}
+
+// setTLS fills TLS details for reused connections, where no handshake was traced
+func (tm *timings) setTLS(state *tls.ConnectionState) {
+	tm.mu.Lock()
+	if tm.tls == nil {
+		tm.tls = state
+	}
+	tm.mu.Unlock()
+}
+
+// MarshalLogObject writes phases that happened, durations are omitted for skipped phases (e.g. reused connection)
+func (tm *timings) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	phase := func(key string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			enc.AddDuration(key, to.Sub(from))
+		}
+	}
+	phase("get_conn", tm.getConn, tm.gotConn)
+	phase("dns", tm.dnsStart, tm.dnsDone)
+	phase("connect", tm.connectStart, tm.connectDone)
+	phase("tls_handshake", tm.tlsStart, tm.tlsDone)
+	phase("write_request", tm.gotConn, tm.wroteRequest)
+	phase("wait_first_byte", tm.wroteRequest, tm.firstByte)
+	phase("time_to_first_byte", tm.start, tm.firstByte)
+
+	enc.AddBool("reused", tm.reused)
+	enc.AddBool("was_idle", tm.wasIdle)
+	if tm.wasIdle {
+		enc.AddDuration("idle_time", tm.idleTime)
+	}
+	if tm.remoteAddr != "" {
+		enc.AddString("remote_addr", tm.remoteAddr)
+	}
+	if tm.tls != nil {
+		enc.AddString("tls_version", tlsVersionName(tm.tls.Version))
+		enc.AddString("tls_cipher", tls.CipherSuiteName(tm.tls.CipherSuite))
+	}
+
+	return nil
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", version)
+	}
+}
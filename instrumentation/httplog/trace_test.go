@@ -0,0 +1,47 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	logs := observe(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: New(srv.Client().Transport, WithTrace())}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+
+	first, ok := entries[0].ContextMap()["trace"].(map[string]interface{})
+	require.True(t, ok, "trace object is logged")
+	for _, phase := range []string{"get_conn", "connect", "tls_handshake", "write_request", "wait_first_byte", "time_to_first_byte"} {
+		assert.IsType(t, time.Duration(0), first[phase], phase)
+	}
+	assert.NotContains(t, first, "dns", "dialed by IP")
+	assert.Equal(t, false, first["reused"])
+	assert.Equal(t, srv.Listener.Addr().String(), first["remote_addr"])
+	assert.Equal(t, "TLS 1.3", first["tls_version"])
+	assert.NotEmpty(t, first["tls_cipher"])
+
+	second := entries[1].ContextMap()["trace"].(map[string]interface{})
+	assert.Equal(t, true, second["reused"])
+	assert.Equal(t, true, second["was_idle"])
+	assert.NotContains(t, second, "connect", "no dial for reused connection")
+	assert.NotContains(t, second, "tls_handshake")
+	assert.Equal(t, "TLS 1.3", second["tls_version"], "taken from response of reused connection")
+}
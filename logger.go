@@ -47,8 +47,9 @@ func init() {
 
 func New(level, namespace string, options ...zap.Option) *zap.Logger {
 	globalLevel := parseLevel(level)
+	// global level is applied by levelCore, so a context may lower it, see BindDebug
 	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= globalLevel && lvl < zapcore.ErrorLevel
+		return lvl < zapcore.ErrorLevel
 	})
 	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 		return lvl >= zapcore.ErrorLevel
@@ -58,10 +59,10 @@ func New(level, namespace string, options ...zap.Option) *zap.Logger {
 	encoderCfg := zap.NewProductionEncoderConfig()
 	encoderCfg.EncodeTime = customTimeEncoder
 	consoleEncoder := zapcore.NewJSONEncoder(encoderCfg)
-	core := zapcore.NewTee(
+	core := &levelCore{Core: zapcore.NewTee(
 		zapcore.NewCore(consoleEncoder, consoleErrors, highPriority),
 		zapcore.NewCore(consoleEncoder, consoleInfos, lowPriority),
-	)
+	), min: globalLevel}
 
 	if len(options) == 0 {
 		options = []zap.Option{zap.WithCaller(true), zap.AddCallerSkip(1)}
@@ -122,6 +123,9 @@ func FromCtx(ctx context.Context, namespace string) *zap.Logger {
 		if bindFields, ok := ctx.Value(BindFieldsKey).([]zap.Field); ok {
 			newLogger = newLogger.With(bindFields...)
 		}
+		if _, ok := GetDebug(ctx); ok {
+			newLogger = withDebug(newLogger)
+		}
 	}
 	if namespace != "" {
 		newLogger = newLogger.Named(namespace)
@@ -144,6 +148,9 @@ func WithContext(l Logger, ctx context.Context) *zap.Logger {
 		if bindFields, ok := ctx.Value(BindFieldsKey).([]zap.Field); ok {
 			newLogger = newLogger.With(bindFields...)
 		}
+		if _, ok := GetDebug(ctx); ok {
+			newLogger = withDebug(newLogger)
+		}
 	}
 
 	return newLogger
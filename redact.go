@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces sensitive values in logs
+const RedactedValue = "[REDACTED]"
+
+// DefaultSensitiveHeaders are masked by instrumentation unless configured otherwise
+var DefaultSensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	HTTPHeaderDebug,
+}
+
+// Redactor masks sensitive values before they are logged. Zero value masks nothing,
+// nil Redactor is valid as well. Redactor is immutable and safe for concurrent use
+type Redactor struct {
+	headers map[string]bool
+	fields  []string
+	jsonRe  *regexp.Regexp
+	formRe  *regexp.Regexp
+}
+
+// NewRedactor creates Redactor masking headers, names are case-insensitive
+func NewRedactor(headers ...string) *Redactor {
+	return (&Redactor{}).WithHeaders(headers...)
+}
+
+// DefaultRedactor masks DefaultSensitiveHeaders
+func DefaultRedactor() *Redactor {
+	return NewRedactor(DefaultSensitiveHeaders...)
+}
+
+// WithHeaders returns a copy of r masking headers as well
+func (r *Redactor) WithHeaders(headers ...string) *Redactor {
+	c := r.clone()
+	for _, h := range headers {
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return c
+}
+
+// WithFields returns a copy of r masking values of body fields as well: keys of JSON objects
+// at any depth and keys of urlencoded forms, names are case-insensitive
+func (r *Redactor) WithFields(fields ...string) *Redactor {
+	c := r.clone()
+	c.fields = append(c.fields, fields...)
+	if len(c.fields) == 0 {
+		return c
+	}
+
+	names := make([]string, len(c.fields))
+	for i, f := range c.fields {
+		names[i] = regexp.QuoteMeta(f)
+	}
+	alt := strings.Join(names, "|")
+	// values are matched lexically, so truncated bodies are masked as well
+	c.jsonRe = regexp.MustCompile(`("(?i:` + alt + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^\s,}\]]+)`)
+	c.formRe = regexp.MustCompile(`((?:^|&)(?i:` + alt + `)=)[^&]*`)
+
+	return c
+}
+
+func (r *Redactor) clone() *Redactor {
+	c := &Redactor{headers: make(map[string]bool)}
+	if r != nil {
+		for h := range r.headers {
+			c.headers[h] = true
+		}
+		c.fields = append(c.fields, r.fields...)
+	}
+
+	return c
+}
+
+// SensitiveHeader reports whether header value must be masked
+func (r *Redactor) SensitiveHeader(name string) bool {
+	return r != nil && r.headers[http.CanonicalHeaderKey(name)]
+}
+
+// Header returns h with sensitive values replaced by RedactedValue.
+// h is never modified: a copy is returned if anything was masked
+func (r *Redactor) Header(h http.Header) http.Header {
+	var masked http.Header
+	for name, values := range h {
+		if !r.SensitiveHeader(name) {
+			continue
+		}
+		if masked == nil {
+			masked = h.Clone()
+		}
+		redacted := make([]string, len(values))
+		for i := range redacted {
+			redacted[i] = RedactedValue
+		}
+		masked[name] = redacted
+	}
+	if masked == nil {
+		return h
+	}
+
+	return masked
+}
+
+// Body returns body with values of sensitive fields replaced by RedactedValue.
+// JSON (application/json, +json) and urlencoded form bodies are masked, other content types are returned as is.
+// body is never modified
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r == nil || len(r.fields) == 0 || len(body) == 0 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return r.jsonRe.ReplaceAll(body, []byte(`$1"`+RedactedValue+`"`))
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.formRe.ReplaceAll(body, []byte("${1}"+RedactedValue))
+	default:
+		return body
+	}
+}
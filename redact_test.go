@@ -0,0 +1,58 @@
+package logger_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"libs/logger"
+)
+
+func TestRedactorHeader(t *testing.T) {
+	h := http.Header{
+		"Authorization": {"Bearer secret"},
+		"X-Token":       {"a", "b"},
+		"Content-Type":  {"application/json"},
+	}
+
+	got := logger.DefaultRedactor().WithHeaders("x-token").Header(h)
+	assert.Equal(t, http.Header{
+		"Authorization": {logger.RedactedValue},
+		"X-Token":       {logger.RedactedValue, logger.RedactedValue},
+		"Content-Type":  {"application/json"},
+	}, got)
+	assert.Equal(t, "Bearer secret", h.Get("Authorization"), "original is not modified")
+
+	var nilRedactor *logger.Redactor
+	assert.Equal(t, h, nilRedactor.Header(h))
+}
+
+func TestRedactorBody(t *testing.T) {
+	r := logger.DefaultRedactor().WithFields("password", "pin")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			"json",
+			"application/json; charset=utf-8",
+			`{"login":"a","Password":"p\"w","card":{"pin": 1234,"ok":true},"list":[{"pin":null}]}`,
+			`{"login":"a","Password":"[REDACTED]","card":{"pin": "[REDACTED]","ok":true},"list":[{"pin":"[REDACTED]"}]}`,
+		},
+		{"truncated json", "application/problem+json", `{"password":"abc`, `{"password":"[REDACTED]"`},
+		{"form", "application/x-www-form-urlencoded", "login=a&PIN=1234&password=", "login=a&PIN=[REDACTED]&password=[REDACTED]"},
+		{"other types", "text/plain", `"password":"p"`, `"password":"p"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(r.Body(tt.contentType, []byte(tt.body))))
+		})
+	}
+
+	assert.Equal(t, `{"password":"p"}`, string(logger.DefaultRedactor().Body("application/json", []byte(`{"password":"p"}`))))
+}